@@ -0,0 +1,51 @@
+package rhttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// BenchmarkServer replays BenchmarkTable through a full Server over a single
+// in-memory keep-alive connection.
+func BenchmarkServer(b *testing.B) {
+	s := New(":0")
+	s.KeepAlive = true
+	table := router.BenchmarkTable()
+	for _, br := range table {
+		s.AddRoute(br.Method, br.Pattern, func(req *request.Request) (*response.Response, error) {
+			return response.Text(200, "ok")
+		})
+	}
+
+	raw := make([][]byte, len(table))
+	for i, br := range table {
+		raw[i] = []byte(br.Method + " " + br.Path + " HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go s.handleConnection(serverConn)
+	reader := bufio.NewReader(clientConn)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		go clientConn.Write(raw[i%len(raw)])
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			b.Fatalf("%s: status %d", raw[i%len(raw)], resp.StatusCode)
+		}
+	}
+}
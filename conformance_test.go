@@ -0,0 +1,142 @@
+package rhttp
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// conformanceServer serves the routes the conformance cases exercise.
+func conformanceServer() *Server {
+	s := New(":0")
+	s.Logger = log.New(io.Discard, "", 0)
+	s.AddRoute("GET", "/hello", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "hello")
+	})
+	s.AddRoute("POST", "/echo", func(req *request.Request) (*response.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, string(body))
+	})
+	s.AddRoute("GET", "/users/:id", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "user "+req.Param("id"))
+	})
+	s.AddRoute("GET", "/stream", func(req *request.Request) (*response.Response, error) {
+		// Hiding Len leaves the length unknown, so the body goes out chunked.
+		return response.New(200, struct{ io.Reader }{strings.NewReader("hello, world")}), nil
+	})
+	return s
+}
+
+// TestConformance feeds exact request bytes through the server and checks
+// the exact bytes it answers with, pinning down status lines, header order,
+// framing and connection handling.
+func TestConformance(t *testing.T) {
+	const textPlain = "Content-Type: text/plain; charset=utf-8\r\n"
+	cases := []struct {
+		name      string
+		configure func(s *Server)
+		request   string
+		response  string
+	}{
+		{
+			name:     "get",
+			request:  "GET /hello HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			response: "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 5\r\n" + textPlain + "\r\nhello",
+		},
+		{
+			name:     "path param",
+			request:  "GET /users/42 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			response: "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 7\r\n" + textPlain + "\r\nuser 42",
+		},
+		{
+			name:     "post with content length",
+			request:  "POST /echo HTTP/1.1\r\nHost: example.com\r\nContent-Length: 4\r\n\r\nping",
+			response: "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 4\r\n" + textPlain + "\r\nping",
+		},
+		{
+			name:     "post with chunked body",
+			request:  "POST /echo HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2\r\nde\r\n0\r\n\r\n",
+			response: "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 5\r\n" + textPlain + "\r\nabcde",
+		},
+		{
+			name:     "chunked response",
+			request:  "GET /stream HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			response: "HTTP/1.1 200 OK\r\nConnection: close\r\nTransfer-Encoding: chunked\r\n\r\nc\r\nhello, world\r\n0\r\n\r\n",
+		},
+		{
+			name:     "not found",
+			request:  "GET /missing HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			response: "HTTP/1.1 404 Not Found\r\nConnection: close\r\nContent-Length: 29\r\n" + textPlain + "\r\nResource '/missing' not found",
+		},
+		{
+			name:     "malformed request line",
+			request:  "GARBAGE\r\n\r\n",
+			response: "HTTP/1.1 400 Bad Request\r\nConnection: close\r\nContent-Length: 11\r\n" + textPlain + "\r\nBad Request",
+		},
+		{
+			name:     "unsupported version",
+			request:  "GET /hello HTTP/2.0\r\nHost: example.com\r\n\r\n",
+			response: "HTTP/1.1 505 HTTP Version Not Supported\r\nConnection: close\r\nContent-Length: 26\r\n" + textPlain + "\r\nHTTP Version Not Supported",
+		},
+		{
+			name:     "unsupported transfer coding",
+			request:  "POST /echo HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: gzip, chunked\r\n\r\n",
+			response: "HTTP/1.1 501 Not Implemented\r\nConnection: close\r\nContent-Length: 15\r\n" + textPlain + "\r\nNot Implemented",
+		},
+		{
+			name:      "request line too long",
+			configure: func(s *Server) { s.MaxRequestLineBytes = 32 },
+			request:   "GET /users/" + strings.Repeat("9", 32) + " HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			response:  "HTTP/1.1 414 URI Too Long\r\nConnection: close\r\nContent-Length: 20\r\n" + textPlain + "\r\nRequest URI Too Long",
+		},
+		{
+			name:      "body too large",
+			configure: func(s *Server) { s.MaxBodyBytes = 3 },
+			request:   "POST /echo HTTP/1.1\r\nHost: example.com\r\nContent-Length: 4\r\n\r\nping",
+			response:  "HTTP/1.1 413 Content Too Large\r\nConnection: close\r\nContent-Length: 24\r\n" + textPlain + "\r\nRequest Entity Too Large",
+		},
+		{
+			name:      "keep-alive",
+			configure: func(s *Server) { s.KeepAlive = true },
+			request: "GET /hello HTTP/1.1\r\nHost: example.com\r\n\r\n" +
+				"POST /echo HTTP/1.1\r\nHost: example.com\r\nContent-Length: 4\r\n\r\nping" +
+				"GET /users/7 HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n",
+			response: "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n" + textPlain + "\r\nhello" +
+				"HTTP/1.1 200 OK\r\nContent-Length: 4\r\n" + textPlain + "\r\nping" +
+				"HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 6\r\n" + textPlain + "\r\nuser 7",
+		},
+		{
+			name:      "http/1.0 keep-alive",
+			configure: func(s *Server) { s.KeepAlive = true },
+			request: "GET /hello HTTP/1.0\r\nConnection: keep-alive\r\n\r\n" +
+				"GET /hello HTTP/1.0\r\n\r\n",
+			response: "HTTP/1.1 200 OK\r\nConnection: keep-alive\r\nContent-Length: 5\r\n" + textPlain + "\r\nhello" +
+				"HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 5\r\n" + textPlain + "\r\nhello",
+		},
+		{
+			name:      "keep-alive ends at malformed request",
+			configure: func(s *Server) { s.KeepAlive = true },
+			request:   "GET /hello HTTP/1.1\r\nHost: example.com\r\n\r\nGARBAGE\r\n\r\n",
+			response: "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n" + textPlain + "\r\nhello" +
+				"HTTP/1.1 400 Bad Request\r\nConnection: close\r\nContent-Length: 11\r\n" + textPlain + "\r\nBad Request",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := conformanceServer()
+			if tc.configure != nil {
+				tc.configure(s)
+			}
+			assert.Equal(t, tc.response, roundTrip(t, s, tc.request))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package rhttp
+
+import (
+	"net"
+	"time"
+)
+
+// ConnState describes where a client connection is in its lifecycle.
+type ConnState int
+
+const (
+	// StateNew is a freshly accepted connection that has not sent a request yet.
+	StateNew ConnState = iota
+	// StateActive is a connection whose request has been read and is being served.
+	StateActive
+	// StateIdle is a connection waiting for its next request.
+	StateIdle
+	// StateClosed is a connection that has been closed. It is a terminal state.
+	StateClosed
+)
+
+var connStateName = map[ConnState]string{
+	StateNew:    "new",
+	StateActive: "active",
+	StateIdle:   "idle",
+	StateClosed: "closed",
+}
+
+func (c ConnState) String() string {
+	return connStateName[c]
+}
+
+// trackedConn is a live connection's entry in the server's registry.
+type trackedConn struct {
+	state     ConnState
+	idleSince time.Time
+}
+
+// setState records a connection state transition in the registry and reports
+// it to the ConnState hook.
+func (s *Server) setState(conn net.Conn, state ConnState) {
+	s.mu.Lock()
+	if state == StateClosed {
+		delete(s.conns, conn)
+	} else {
+		tc := s.conns[conn]
+		if tc == nil {
+			tc = &trackedConn{}
+			s.conns[conn] = tc
+		}
+		tc.state = state
+		if state == StateIdle {
+			tc.idleSince = time.Now()
+		}
+	}
+	s.mu.Unlock()
+
+	if s.ConnState != nil {
+		s.ConnState(conn, state)
+	}
+}
+
+// admit registers a newly accepted connection. At the MaxConnections limit it
+// makes room by closing the connection that has been idle the longest, and
+// refuses conn if every connection is busy.
+func (s *Server) admit(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.MaxConnections > 0 && len(s.conns) >= s.MaxConnections {
+		var oldest net.Conn
+		var oldestSince time.Time
+		for c, tc := range s.conns {
+			if tc.state == StateIdle && (oldest == nil || tc.idleSince.Before(oldestSince)) {
+				oldest, oldestSince = c, tc.idleSince
+			}
+		}
+		if oldest == nil {
+			return false
+		}
+		// Its goroutine sees the closed connection and reports StateClosed.
+		oldest.Close()
+		delete(s.conns, oldest)
+	}
+	s.conns[conn] = &trackedConn{state: StateNew}
+	return true
+}
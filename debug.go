@@ -0,0 +1,47 @@
+package rhttp
+
+import (
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+)
+
+// redactedHeaders are replaced by DebugEchoHandler, since echoing
+// credentials back could leak them into logs or shared screenshots.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// debugEcho is the JSON body written by DebugEchoHandler.
+type debugEcho struct {
+	Method     string              `json:"method"`
+	Target     string              `json:"target"`
+	Version    string              `json:"version"`
+	Headers    map[string]string   `json:"headers"`
+	PathParams map[string]string   `json:"path_params"`
+	Query      map[string][]string `json:"query"`
+}
+
+// DebugEchoHandler responds with a JSON description of the request as the
+// server parsed it: method, target, version, headers, path parameters and
+// query, which helps check what a proxy in front of the server forwards.
+// Authorization, Proxy-Authorization and Cookie values are redacted. It is
+// meant for diagnostics and should not be routed in production.
+func DebugEchoHandler(req *request.Request) (*response.Response, error) {
+	headers := make(map[string]string, len(req.Headers))
+	for key, value := range req.Headers {
+		if redactedHeaders[key] {
+			value = "[redacted]"
+		}
+		headers[key] = value
+	}
+	return response.JSON(200, debugEcho{
+		Method:     req.Method,
+		Target:     req.Target,
+		Version:    req.Version,
+		Headers:    headers,
+		PathParams: req.PathParams,
+		Query:      req.Query,
+	})
+}
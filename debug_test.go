@@ -0,0 +1,51 @@
+package rhttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugEchoHandler(t *testing.T) {
+	req := &request.Request{
+		Method:  "GET",
+		Target:  "/debug/alice?tag=a&tag=b",
+		Query:   url.Values{"tag": {"a", "b"}},
+		Version: "HTTP/1.1",
+		Headers: map[string]string{
+			"Authorization":   "Bearer secret-token",
+			"Cookie":          "session=secret",
+			"X-Forwarded-For": "203.0.113.9",
+		},
+		PathParams: map[string]string{"name": "alice"},
+	}
+	resp, err := DebugEchoHandler(req)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "secret")
+
+	var echo struct {
+		Method     string              `json:"method"`
+		Target     string              `json:"target"`
+		Version    string              `json:"version"`
+		Headers    map[string]string   `json:"headers"`
+		PathParams map[string]string   `json:"path_params"`
+		Query      map[string][]string `json:"query"`
+	}
+	require.NoError(t, json.Unmarshal(body, &echo))
+	assert.Equal(t, "GET", echo.Method)
+	assert.Equal(t, "/debug/alice?tag=a&tag=b", echo.Target)
+	assert.Equal(t, "HTTP/1.1", echo.Version)
+	assert.Equal(t, "203.0.113.9", echo.Headers["X-Forwarded-For"])
+	assert.Equal(t, "[redacted]", echo.Headers["Authorization"])
+	assert.Equal(t, "[redacted]", echo.Headers["Cookie"])
+	assert.Equal(t, map[string]string{"name": "alice"}, echo.PathParams)
+	assert.Equal(t, []string{"a", "b"}, echo.Query["tag"])
+}
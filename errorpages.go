@@ -0,0 +1,83 @@
+package rhttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/response"
+)
+
+// ErrorPage is the data an error page template from Server.ErrorPages is
+// rendered with.
+type ErrorPage struct {
+	StatusCode int
+	StatusText string
+	// Message is the error's message for HTTPErrors, and the status text
+	// for other errors, whose details are not for clients.
+	Message string
+}
+
+// errorPages returns the templates in ErrorPages keyed by status. They are
+// parsed on first use, so a broken page fails Serve rather than the request
+// that would have rendered it.
+func (s *Server) errorPages() (map[int]*template.Template, error) {
+	s.errorPagesOnce.Do(func() {
+		if s.ErrorPages == nil {
+			return
+		}
+		names, err := fs.Glob(s.ErrorPages, "[1-5][0-9][0-9].html")
+		if err != nil {
+			s.errorPagesErr = fmt.Errorf("invalid ErrorPages: %w", err)
+			return
+		}
+		pages := make(map[int]*template.Template, len(names))
+		for _, name := range names {
+			tmpl, err := template.ParseFS(s.ErrorPages, name)
+			if err != nil {
+				s.errorPagesErr = fmt.Errorf("invalid error page %s: %w", name, err)
+				return
+			}
+			status, _ := strconv.Atoi(strings.TrimSuffix(name, ".html"))
+			pages[status] = tmpl
+		}
+		s.parsedErrorPages = pages
+	})
+	return s.parsedErrorPages, s.errorPagesErr
+}
+
+// renderErrorPage wraps fallback, rendering the page from pages for the
+// error's status instead when there is one.
+func (s *Server) renderErrorPage(pages map[int]*template.Template, fallback func(err error) (*response.Response, error)) func(err error) (*response.Response, error) {
+	return func(err error) (*response.Response, error) {
+		page := ErrorPage{StatusCode: http.StatusInternalServerError}
+		var httpErr *httperrors.HTTPError
+		if errors.As(err, &httpErr) {
+			page.StatusCode, page.Message = httpErr.StatusCode, httpErr.Message
+		}
+		page.StatusText = http.StatusText(page.StatusCode)
+		if page.Message == "" {
+			page.Message = page.StatusText
+		}
+
+		tmpl, ok := pages[page.StatusCode]
+		if !ok {
+			return fallback(err)
+		}
+		var body bytes.Buffer
+		if execErr := tmpl.Execute(&body, page); execErr != nil {
+			s.logf("error page %d.html: %v", page.StatusCode, execErr)
+			return fallback(err)
+		}
+		resp := response.New(page.StatusCode, bytes.NewReader(body.Bytes()))
+		resp.Headers["Content-Type"] = "text/html; charset=utf-8"
+		resp.Headers["Content-Length"] = strconv.Itoa(body.Len())
+		return resp, nil
+	}
+}
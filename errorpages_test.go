@@ -0,0 +1,79 @@
+package rhttp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorPages(t *testing.T) {
+	s := New(":0")
+	s.ErrorPages = fstest.MapFS{
+		"404.html": {Data: []byte("<h1>{{.StatusCode}} {{.StatusText}}</h1><p>{{.Message}}</p>")},
+		"500.html": {Data: []byte("<h1>Sorry</h1><p>{{.Message}}</p>")},
+	}
+	s.AddRoute("GET", "/broken", func(req *request.Request) (*response.Response, error) {
+		return nil, errors.New("database password rejected")
+	})
+	s.AddRoute("GET", "/forbidden", func(req *request.Request) (*response.Response, error) {
+		return nil, httperrors.NewForbidden("keep out")
+	})
+
+	testCases := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "Page for unmatched route",
+			path:        "/missing<b>",
+			status:      404,
+			contentType: "text/html; charset=utf-8",
+			body:        "<h1>404 Not Found</h1><p>Resource &#39;/missing&lt;b&gt;&#39; not found</p>",
+		},
+		{
+			name:        "Internal details hidden",
+			path:        "/broken",
+			status:      500,
+			contentType: "text/html; charset=utf-8",
+			body:        "<h1>Sorry</h1><p>Internal Server Error</p>",
+		},
+		{
+			name:        "Default without a page",
+			path:        "/forbidden",
+			status:      403,
+			contentType: "text/plain; charset=utf-8",
+			body:        "keep out",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, r := dial(t, s)
+			send(conn, "GET "+tc.path+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+			resp, body := readResponse(t, r)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tc.body, body)
+		})
+	}
+}
+
+func TestErrorPagesParsedAtStart(t *testing.T) {
+	s := New(":0")
+	s.ErrorPages = fstest.MapFS{
+		"404.html": {Data: []byte("<h1>{{.StatusCode</h1>")},
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.ErrorContains(t, s.Serve(ln), "invalid error page 404.html")
+	assert.Panics(t, func() { s.HTTPHandler() })
+}
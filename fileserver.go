@@ -0,0 +1,130 @@
+package rhttp
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// FileServerOptions configures FileServerFSWithOptions.
+type FileServerOptions struct {
+	// Browse renders an HTML listing for directories without an index.html.
+	Browse bool
+}
+
+// FileServerFS returns a handler that serves files from fsys, e.g. assets
+// embedded with //go:embed. The decoded request path names the file; a
+// directory is served through its index.html, and missing files and
+// directories without one are reported as 404.
+func FileServerFS(fsys fs.FS) router.Handler {
+	return FileServerFSWithOptions(fsys, FileServerOptions{})
+}
+
+// FileServerFSWithOptions is like FileServerFS but configurable, e.g. to list
+// directory contents.
+func FileServerFSWithOptions(fsys fs.FS, opts FileServerOptions) router.Handler {
+	return func(req *request.Request) (*response.Response, error) {
+		urlPath := req.Path
+		name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+		if name == "" {
+			name = "."
+		}
+		if !fs.ValidPath(name) {
+			return nil, httperrors.NewNotFound(urlPath)
+		}
+
+		f, info, err := openFile(fsys, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, httperrors.NewNotFound(urlPath)
+			}
+			return nil, err
+		}
+		if info.IsDir() {
+			f.Close()
+			index := path.Join(name, "index.html")
+			f, info, err = openFile(fsys, index)
+			switch {
+			case err == nil && !info.IsDir():
+				name = index
+			case err == nil:
+				f.Close()
+				fallthrough
+			case errors.Is(err, fs.ErrNotExist):
+				if opts.Browse {
+					return dirListing(fsys, name, urlPath)
+				}
+				return nil, httperrors.NewNotFound(urlPath)
+			default:
+				return nil, err
+			}
+		}
+
+		resp := response.New(200, f)
+		resp.Headers["Content-Type"] = contentTypeByName(name)
+		resp.Headers["Content-Length"] = strconv.FormatInt(info.Size(), 10)
+		return resp, nil
+	}
+}
+
+// openFile opens name in fsys along with its file info.
+func openFile(fsys fs.FS, name string) (fs.File, fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// dirListing renders the entries of directory name as an HTML list of links.
+// Names are escaped, since they are not under the server's control.
+func dirListing(fsys fs.FS, name, urlPath string) (*response.Response, error) {
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	title := html.EscapeString(path.Clean("/" + urlPath))
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<title>Index of %s</title>\n<h1>Index of %s</h1>\n<ul>\n", title, title)
+	for _, entry := range entries {
+		display := entry.Name()
+		if entry.IsDir() {
+			display += "/"
+		}
+		href := (&url.URL{Path: path.Join("/", urlPath, display)}).String()
+		if entry.IsDir() {
+			href += "/"
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(display))
+	}
+	b.WriteString("</ul>\n")
+
+	resp := response.New(200, strings.NewReader(b.String()))
+	resp.Headers["Content-Type"] = "text/html; charset=utf-8"
+	resp.Headers["Content-Length"] = strconv.Itoa(b.Len())
+	return resp, nil
+}
+
+// contentTypeByName guesses a file's content type from its extension.
+func contentTypeByName(name string) string {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,105 @@
+package rhttp
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileServerFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"hello.txt":     {Data: []byte("hello, world")},
+		"css/site.css":  {Data: []byte("body{}")},
+		"data.unknownx": {Data: []byte{0x01}},
+	}
+	s := New(":0")
+	s.AddRoute("GET", "/css/:name", FileServerFS(fsys))
+	s.AddRoute("GET", "/:name", FileServerFS(fsys))
+
+	testCases := []struct {
+		name        string
+		target      string
+		status      string
+		contentType string
+		body        string
+	}{
+		{name: "Text file", target: "/hello.txt", status: "200 OK", contentType: "text/plain; charset=utf-8", body: "hello, world"},
+		{name: "Nested file", target: "/css/site.css", status: "200 OK", contentType: "text/css; charset=utf-8", body: "body{}"},
+		{name: "Unknown extension", target: "/data.unknownx", status: "200 OK", contentType: "application/octet-stream", body: "\x01"},
+		{name: "Missing file", target: "/missing.txt", status: "404 Not Found"},
+		{name: "Directory", target: "/css", status: "404 Not Found"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := roundTrip(t, s, "GET "+tc.target+" HTTP/1.1\r\n\r\n")
+			assert.Contains(t, out, "HTTP/1.1 "+tc.status+"\r\n")
+			if tc.contentType != "" {
+				assert.Contains(t, out, "Content-Type: "+tc.contentType+"\r\n")
+				assert.Contains(t, out, "\r\n\r\n"+tc.body)
+			}
+		})
+	}
+}
+
+func TestFileServerDirectories(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/index.html":                        {Data: []byte("<h1>Docs</h1>")},
+		"files/report.txt":                       {Data: []byte("report")},
+		"files/<img src=x onerror=alert(1)>.txt": {Data: []byte("evil")},
+		"files/sub/a.txt":                        {Data: []byte("a")},
+		"files/my report.txt":                    {Data: []byte("spaced")},
+		"files/ünïcode.txt":                      {Data: []byte("unicode")},
+	}
+
+	t.Run("Index document", func(t *testing.T) {
+		s := New(":0")
+		s.AddRoute("GET", "/:dir", FileServerFS(fsys))
+		out := roundTrip(t, s, "GET /docs HTTP/1.1\r\n\r\n")
+		assert.Contains(t, out, "HTTP/1.1 200 OK\r\n")
+		assert.Contains(t, out, "Content-Type: text/html; charset=utf-8\r\n")
+		assert.Contains(t, out, "\r\n\r\n<h1>Docs</h1>")
+	})
+
+	t.Run("Browsing disabled", func(t *testing.T) {
+		s := New(":0")
+		s.AddRoute("GET", "/:dir", FileServerFS(fsys))
+		out := roundTrip(t, s, "GET /files HTTP/1.1\r\n\r\n")
+		assert.Contains(t, out, "HTTP/1.1 404 Not Found\r\n")
+	})
+
+	t.Run("Listing", func(t *testing.T) {
+		s := New(":0")
+		s.AddRoute("GET", "/:dir", FileServerFSWithOptions(fsys, FileServerOptions{Browse: true}))
+		out := roundTrip(t, s, "GET /files HTTP/1.1\r\n\r\n")
+		assert.Contains(t, out, "HTTP/1.1 200 OK\r\n")
+		assert.Contains(t, out, `<a href="/files/report.txt">report.txt</a>`)
+		assert.Contains(t, out, `<a href="/files/sub/">sub/</a>`)
+		assert.Contains(t, out, ">&lt;img src=x onerror=alert(1)&gt;.txt</a>")
+		assert.NotContains(t, out, "<img", "file names must be escaped")
+	})
+
+	t.Run("Listed links resolve", func(t *testing.T) {
+		s := New(":0")
+		s.AddRoute("GET", "/:dir", FileServerFSWithOptions(fsys, FileServerOptions{Browse: true}))
+		s.AddRoute("GET", "/files/:name", FileServerFS(fsys))
+		out := roundTrip(t, s, "GET /files HTTP/1.1\r\n\r\n")
+		for name, body := range map[string]string{"my report.txt": "spaced", "ünïcode.txt": "unicode"} {
+			match := regexp.MustCompile(`<a href="([^"]+)">` + regexp.QuoteMeta(name) + `</a>`).FindStringSubmatch(out)
+			require.NotNil(t, match, "no link for %q in %s", name, out)
+			file := roundTrip(t, s, "GET "+match[1]+" HTTP/1.1\r\n\r\n")
+			assert.Contains(t, file, "HTTP/1.1 200 OK\r\n", match[1])
+			assert.True(t, strings.HasSuffix(file, "\r\n\r\n"+body), file)
+		}
+	})
+
+	t.Run("Malformed escape", func(t *testing.T) {
+		s := New(":0")
+		s.AddRoute("GET", "/files/:name", FileServerFS(fsys))
+		out := roundTrip(t, s, "GET /files/bad%zz.txt HTTP/1.1\r\n\r\n")
+		assert.Contains(t, out, "HTTP/1.1 400 Bad Request\r\n")
+	})
+}
@@ -16,10 +16,62 @@ func NewBadRequest(message string) *HTTPError {
 	return &HTTPError{StatusCode: 400, Message: message}
 }
 
+func NewUnauthorized(message string) *HTTPError {
+	return &HTTPError{StatusCode: 401, Message: message}
+}
+
+func NewForbidden(message string) *HTTPError {
+	return &HTTPError{StatusCode: 403, Message: message}
+}
+
 func NewNotFound(resource string) *HTTPError {
 	return &HTTPError{StatusCode: 404, Message: fmt.Sprintf("Resource '%s' not found", resource)}
 }
 
+func NewMethodNotAllowed(method string) *HTTPError {
+	return &HTTPError{StatusCode: 405, Message: fmt.Sprintf("Method '%s' not allowed", method)}
+}
+
+func NewPreconditionFailed(message string) *HTTPError {
+	return &HTTPError{StatusCode: 412, Message: message}
+}
+
+func NewPayloadTooLarge(limit int64) *HTTPError {
+	return &HTTPError{StatusCode: 413, Message: fmt.Sprintf("Request body exceeds the limit of %d bytes", limit)}
+}
+
+func NewURITooLong(limit int) *HTTPError {
+	return &HTTPError{StatusCode: 414, Message: fmt.Sprintf("Request line exceeds the limit of %d bytes", limit)}
+}
+
+func NewUnsupportedMediaType(message string) *HTTPError {
+	return &HTTPError{StatusCode: 415, Message: message}
+}
+
+func NewRangeNotSatisfiable(size int64) *HTTPError {
+	return &HTTPError{StatusCode: 416, Message: fmt.Sprintf("Range not satisfiable for a resource of %d bytes", size)}
+}
+
+func NewTooManyRequests(message string) *HTTPError {
+	return &HTTPError{StatusCode: 429, Message: message}
+}
+
+func NewRequestHeaderFieldsTooLarge(message string) *HTTPError {
+	return &HTTPError{StatusCode: 431, Message: message}
+}
+
 func NewInternalServerError(message string) *HTTPError {
 	return &HTTPError{StatusCode: 500, Message: message}
 }
+
+func NewNotImplemented(message string) *HTTPError {
+	return &HTTPError{StatusCode: 501, Message: message}
+}
+
+func NewServiceUnavailable(message string) *HTTPError {
+	return &HTTPError{StatusCode: 503, Message: message}
+}
+
+func NewHTTPVersionNotSupported(version string) *HTTPError {
+	return &HTTPError{StatusCode: 505, Message: fmt.Sprintf("HTTP version '%s' not supported", version)}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package rhttp
+
+import "errors"
+
+// dupFD reports that inherited listeners are not supported here, as
+// net.FileListener is not.
+func dupFD(fd uintptr) (uintptr, error) {
+	return 0, errors.New("listener handoff is not supported on this platform")
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package rhttp
+
+import "syscall"
+
+// dupFD duplicates fd, so the caller keeps ownership of the original.
+func dupFD(fd uintptr) (uintptr, error) {
+	dup, err := syscall.Dup(int(fd))
+	if err != nil {
+		return 0, err
+	}
+	syscall.CloseOnExec(dup)
+	return uintptr(dup), nil
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// accessRecord describes one handled request.
+type accessRecord struct {
+	start      time.Time
+	method     string
+	target     string
+	version    string
+	remoteAddr string
+	requestID  string
+	status     int
+	bytes      int64
+	duration   time.Duration
+}
+
+// path returns the request target without its query.
+func (rec *accessRecord) path() string {
+	path, _, _ := strings.Cut(rec.target, "?")
+	return path
+}
+
+// AccessLog writes one line per request to w in the Common Log Format.
+func AccessLog(w io.Writer) Middleware {
+	var mu sync.Mutex
+	return accessLog(func(rec *accessRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %d\n",
+			rec.remoteAddr, rec.start.Format("02/Jan/2006:15:04:05 -0700"),
+			rec.method, rec.target, rec.version, rec.status, rec.bytes)
+	})
+}
+
+// SlowAccessLog is like AccessLog but only logs requests that took at least
+// threshold, measured until the response was sent, appending the duration to
+// the line. It keeps logs quiet while still catching slow endpoints.
+func SlowAccessLog(w io.Writer, threshold time.Duration) Middleware {
+	var mu sync.Mutex
+	return accessLog(func(rec *accessRecord) {
+		if rec.duration < threshold {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %d %s\n",
+			rec.remoteAddr, rec.start.Format("02/Jan/2006:15:04:05 -0700"),
+			rec.method, rec.target, rec.version, rec.status, rec.bytes, rec.duration)
+	})
+}
+
+// AccessLogJSON writes one JSON object per request to w, for log aggregators.
+// request_id is taken from the X-Request-Id header and omitted when absent.
+func AccessLogJSON(w io.Writer) Middleware {
+	var mu sync.Mutex
+	return accessLog(func(rec *accessRecord) {
+		line, err := json.Marshal(struct {
+			Time       string  `json:"time"`
+			Method     string  `json:"method"`
+			Path       string  `json:"path"`
+			Status     int     `json:"status"`
+			Bytes      int64   `json:"bytes"`
+			DurationMS float64 `json:"duration_ms"`
+			RemoteAddr string  `json:"remote_addr"`
+			RequestID  string  `json:"request_id,omitempty"`
+		}{
+			Time:       rec.start.UTC().Format(time.RFC3339Nano),
+			Method:     rec.method,
+			Path:       rec.path(),
+			Status:     rec.status,
+			Bytes:      rec.bytes,
+			DurationMS: float64(rec.duration.Microseconds()) / 1000,
+			RemoteAddr: rec.remoteAddr,
+			RequestID:  rec.requestID,
+		})
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(line, '\n'))
+	})
+}
+
+// accessLog calls emit once the response has been sent. Returned bodies are
+// counted as the server writes them, so the record is emitted when the body
+// is closed; streamed responses are counted through the ResponseWriter.
+func accessLog(emit func(rec *accessRecord)) Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			rec := &accessRecord{
+				start:      time.Now(),
+				method:     req.Method,
+				target:     req.Target,
+				version:    req.Version,
+				remoteAddr: req.RemoteAddr,
+				requestID:  req.Headers["X-Request-Id"],
+			}
+			done := func() {
+				rec.duration = time.Since(rec.start)
+				emit(rec)
+			}
+
+			var cw *countingWriter
+			if w, ok := response.WriterFromContext(req.Context()); ok {
+				cw = &countingWriter{ResponseWriter: w}
+				req = req.WithContext(response.NewContext(req.Context(), cw))
+			}
+
+			resp, err := next(req)
+			switch {
+			case err != nil:
+				rec.status = 500
+				var httpErr *httperrors.HTTPError
+				if errors.As(err, &httpErr) {
+					rec.status = httpErr.StatusCode
+				}
+				done()
+			case resp == nil:
+				// The handler streamed its response through the ResponseWriter.
+				if cw != nil {
+					rec.status, rec.bytes = cw.status, cw.bytes
+				}
+				done()
+			case resp.Body == nil:
+				rec.status = resp.StatusCode
+				done()
+			default:
+				rec.status = resp.StatusCode
+				resp.Body = &countingBody{r: resp.Body, rec: rec, done: done}
+			}
+			return resp, err
+		}
+	}
+}
+
+// countingBody counts the bytes read from a response body and reports the
+// request when the server closes it.
+type countingBody struct {
+	r      io.Reader
+	rec    *accessRecord
+	done   func()
+	closed bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.rec.bytes += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	defer b.done()
+	if closer, ok := b.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// countingWriter records the status and body size of a streamed response.
+type countingWriter struct {
+	response.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *countingWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = 200
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *countingWriter) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if w.status == 0 {
+		if _, ok := w.Header()["Content-Type"]; !ok {
+			w.Header()["Content-Type"] = "application/json; charset=utf-8"
+		}
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogJSON(t *testing.T) {
+	var logs bytes.Buffer
+	handler := AccessLogJSON(&logs)(okHandler)
+
+	req := &request.Request{
+		Method:     "GET",
+		Target:     "/items?page=2",
+		Version:    "HTTP/1.1",
+		Headers:    map[string]string{"X-Request-Id": "abc123"},
+		RemoteAddr: "192.0.2.1:5555",
+	}
+	resp, err := handler(req)
+	require.NoError(t, err)
+	assert.Zero(t, logs.Len(), "the line is written once the response has been sent")
+	require.NoError(t, resp.Write(io.Discard))
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/items", entry["path"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, float64(2), entry["bytes"])
+	assert.Equal(t, "192.0.2.1:5555", entry["remote_addr"])
+	assert.Equal(t, "abc123", entry["request_id"])
+	assert.Contains(t, entry, "time")
+	assert.Contains(t, entry, "duration_ms")
+}
+
+func TestAccessLogJSONOtherOutcomes(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler func(req *request.Request) (*response.Response, error)
+		status  float64
+		bytes   float64
+	}{
+		{
+			name: "Handler error",
+			handler: func(req *request.Request) (*response.Response, error) {
+				return nil, httperrors.NewNotFound("item")
+			},
+			status: 404,
+		},
+		{
+			name: "Streamed response",
+			handler: func(req *request.Request) (*response.Response, error) {
+				w, _ := response.WriterFromContext(req.Context())
+				w.WriteHeader(201)
+				w.WriteString("hello")
+				return nil, nil
+			},
+			status: 201,
+			bytes:  5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			handler := AccessLogJSON(&logs)(tc.handler)
+
+			req := &request.Request{Method: "POST", Target: "/items", Headers: map[string]string{}}
+			req = req.WithContext(response.NewContext(req.Context(), response.NewWriter(io.Discard)))
+			_, _ = handler(req)
+
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+			assert.Equal(t, tc.status, entry["status"])
+			assert.Equal(t, tc.bytes, entry["bytes"])
+			assert.NotContains(t, entry, "request_id")
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	handler := AccessLog(&logs)(okHandler)
+
+	req := &request.Request{Method: "GET", Target: "/items", Version: "HTTP/1.1", Headers: map[string]string{}, RemoteAddr: "192.0.2.1:5555"}
+	resp, err := handler(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Write(io.Discard))
+
+	assert.Regexp(t, `^192\.0\.2\.1:5555 - - \[.+\] "GET /items HTTP/1\.1" 200 2\n$`, logs.String())
+}
+
+func TestSlowAccessLog(t *testing.T) {
+	slowHandler := func(req *request.Request) (*response.Response, error) {
+		time.Sleep(30 * time.Millisecond)
+		return response.Text(200, "ok")
+	}
+	testCases := []struct {
+		name    string
+		handler func(req *request.Request) (*response.Response, error)
+		logged  bool
+	}{
+		{name: "Fast request", handler: okHandler},
+		{name: "Slow request", handler: slowHandler, logged: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			handler := SlowAccessLog(&logs, 20*time.Millisecond)(tc.handler)
+
+			req := &request.Request{Method: "GET", Target: "/items", Version: "HTTP/1.1", Headers: map[string]string{}, RemoteAddr: "192.0.2.1:5555"}
+			resp, err := handler(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Write(io.Discard))
+
+			if !tc.logged {
+				assert.Zero(t, logs.Len())
+				return
+			}
+			assert.Regexp(t, `^192\.0\.2\.1:5555 - - \[.+\] "GET /items HTTP/1\.1" 200 2 \d+(\.\d+)?ms\n$`, logs.String())
+		})
+	}
+}
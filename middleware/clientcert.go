@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/x509"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// ClientCertSubjectKey is the request value store key under which
+// RequireClientCert stores the client certificate's subject, a pkix.Name.
+const ClientCertSubjectKey = "rhttp.client-cert-subject"
+
+// RequireClientCert admits only requests made with a client certificate that
+// the TLS handshake verified against the listener's ClientCAs. Requests
+// without one get 401; the leaf certificate is then passed to verify, and a
+// verify error gives 403. On success the subject is stored under
+// ClientCertSubjectKey.
+func RequireClientCert(verify func(*x509.Certificate) error) Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+				return nil, httperrors.NewUnauthorized("a verified client certificate is required")
+			}
+			leaf := req.TLS.VerifiedChains[0][0]
+			if err := verify(leaf); err != nil {
+				return nil, httperrors.NewForbidden("client certificate rejected")
+			}
+			req.Set(ClientCertSubjectKey, leaf.Subject)
+			return next(req)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// MaxConcurrentPerIP limits each client, as identified by Request.ClientIP,
+// to n requests in flight at once. Further requests get 429 until one of the
+// client's handlers returns.
+func MaxConcurrentPerIP(n int) Middleware {
+	var mu sync.Mutex
+	inFlight := make(map[string]int)
+
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			ip := req.ClientIP()
+			mu.Lock()
+			if inFlight[ip] >= n {
+				mu.Unlock()
+				return nil, httperrors.NewTooManyRequests(fmt.Sprintf("more than %d concurrent requests", n))
+			}
+			inFlight[ip]++
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if inFlight[ip]--; inFlight[ip] == 0 {
+					delete(inFlight, ip)
+				}
+			}()
+			return next(req)
+		}
+	}
+}
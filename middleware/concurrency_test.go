@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxConcurrentPerIP(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	handler := MaxConcurrentPerIP(2)(func(req *request.Request) (*response.Response, error) {
+		entered <- struct{}{}
+		<-release
+		return response.Text(200, "ok")
+	})
+	requestFrom := func(addr string) *request.Request {
+		return &request.Request{Method: "GET", Headers: map[string]string{}, RemoteAddr: addr}
+	}
+
+	// Occupy both slots of one client.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(requestFrom("192.0.2.1:1000"))
+		}()
+		<-entered
+	}
+
+	_, err := handler(requestFrom("192.0.2.1:2000"))
+	var httpErr *httperrors.HTTPError
+	require.True(t, errors.As(err, &httpErr), "the third concurrent request should be refused")
+	assert.Equal(t, 429, httpErr.StatusCode)
+
+	// Another client is unaffected.
+	done := make(chan error)
+	go func() {
+		_, err := handler(requestFrom("198.51.100.9:1000"))
+		done <- err
+	}()
+	<-entered
+
+	close(release)
+	require.NoError(t, <-done)
+	wg.Wait()
+
+	// Slots are released once handlers return.
+	go func() { <-entered }()
+	resp, err := handler(requestFrom("192.0.2.1:3000"))
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// AcceptContentTypes rejects requests whose body has a media type outside
+// types with 415, before the handler runs. Parameters such as charset are
+// ignored, and an entry like "image/*" accepts any subtype. Requests without
+// a body are let through.
+func AcceptContentTypes(types ...string) Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			header := req.Headers["Content-Type"]
+			if header == "" && !hasBody(req) {
+				return next(req)
+			}
+			mediaType, _, err := mime.ParseMediaType(header)
+			if err != nil || !matchesMediaType(types, mediaType) {
+				return nil, httperrors.NewUnsupportedMediaType(fmt.Sprintf(
+					"Content-Type '%s' is not supported; expected %s", header, strings.Join(types, ", ")))
+			}
+			return next(req)
+		}
+	}
+}
+
+// hasBody reports whether the request declares a body.
+func hasBody(req *request.Request) bool {
+	if req.Headers["Transfer-Encoding"] != "" {
+		return true
+	}
+	length := req.Headers["Content-Length"]
+	return length != "" && length != "0"
+}
+
+// matchesMediaType reports whether mediaType is one of types.
+func matchesMediaType(types []string, mediaType string) bool {
+	for _, t := range types {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(strings.ToLower(mediaType), strings.ToLower(prefix)+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptContentTypes(t *testing.T) {
+	handler := AcceptContentTypes("application/json", "image/*")(okHandler)
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+		allowed bool
+	}{
+		{name: "Exact match", headers: map[string]string{"Content-Type": "application/json", "Content-Length": "2"}, allowed: true},
+		{name: "Parameters ignored", headers: map[string]string{"Content-Type": "Application/JSON; charset=utf-8", "Content-Length": "2"}, allowed: true},
+		{name: "Wildcard subtype", headers: map[string]string{"Content-Type": "image/png", "Content-Length": "2"}, allowed: true},
+		{name: "No body", headers: map[string]string{}, allowed: true},
+		{name: "Disallowed type", headers: map[string]string{"Content-Type": "text/xml", "Content-Length": "2"}},
+		{name: "Body without type", headers: map[string]string{"Content-Length": "2"}},
+		{name: "Malformed type", headers: map[string]string{"Content-Type": "json;;", "Content-Length": "2"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := handler(&request.Request{Method: "POST", Headers: tc.headers})
+			if tc.allowed {
+				require.NoError(t, err)
+				assert.Equal(t, 200, resp.StatusCode)
+				return
+			}
+			var httpErr *httperrors.HTTPError
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, 415, httpErr.StatusCode)
+			assert.Contains(t, httpErr.Message, "expected application/json, image/*")
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins allowed to make requests; "*" allows any.
+	AllowedOrigins []string
+	// AllowedMethods is sent in reply to preflights. If empty, the methods in
+	// the Allow header of the preflight response are used instead, so the
+	// server's automatic OPTIONS handling decides.
+	AllowedMethods []string
+	// AllowedHeaders is sent in reply to preflights. If empty, the headers
+	// the client asked for are allowed.
+	AllowedHeaders []string
+	// AllowCredentials lets the browser send cookies and credentials.
+	AllowCredentials bool
+	// MaxAge tells the browser how long it may cache a preflight result.
+	MaxAge time.Duration
+}
+
+// CORS adds Cross-Origin Resource Sharing headers to responses for allowed
+// origins. A preflight is passed on to the next handler so an OPTIONS route,
+// or the server's automatic OPTIONS response, can answer it; the CORS headers
+// are then added to that response, or to an empty 204 if it failed.
+func CORS(opts CORSOptions) Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			origin := req.Headers["Origin"]
+			if origin == "" || !opts.allowsOrigin(origin) {
+				return next(req)
+			}
+
+			preflight := req.Method == "OPTIONS" && req.Headers["Access-Control-Request-Method"] != ""
+			resp, err := next(req)
+			if !preflight {
+				if resp != nil {
+					opts.setOriginHeaders(resp.Headers, origin)
+				}
+				return resp, err
+			}
+
+			if err != nil || resp == nil || resp.StatusCode >= 300 {
+				resp = response.New(204, nil)
+			}
+			opts.setOriginHeaders(resp.Headers, origin)
+			methods := strings.Join(opts.AllowedMethods, ", ")
+			if methods == "" {
+				methods = resp.Headers["Allow"]
+			}
+			if methods == "" {
+				methods = req.Headers["Access-Control-Request-Method"]
+			}
+			resp.Headers["Access-Control-Allow-Methods"] = methods
+			headers := strings.Join(opts.AllowedHeaders, ", ")
+			if headers == "" {
+				headers = req.Headers["Access-Control-Request-Headers"]
+			}
+			if headers != "" {
+				resp.Headers["Access-Control-Allow-Headers"] = headers
+			}
+			if opts.MaxAge > 0 {
+				resp.Headers["Access-Control-Max-Age"] = strconv.Itoa(int(opts.MaxAge.Seconds()))
+			}
+			return resp, nil
+		}
+	}
+}
+
+func (opts CORSOptions) allowsOrigin(origin string) bool {
+	for _, allowed := range opts.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts CORSOptions) setOriginHeaders(headers map[string]string, origin string) {
+	if opts.AllowCredentials {
+		headers["Access-Control-Allow-Credentials"] = "true"
+	}
+	// Echo the origin unless any origin is allowed without credentials, in
+	// which case the response does not vary by origin.
+	for _, allowed := range opts.AllowedOrigins {
+		if allowed == "*" && !opts.AllowCredentials {
+			headers["Access-Control-Allow-Origin"] = "*"
+			return
+		}
+	}
+	headers["Access-Control-Allow-Origin"] = origin
+	addVary(headers, "Origin")
+}
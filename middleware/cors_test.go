@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins:   []string{"https://app.example"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	notFound := func(req *request.Request) (*response.Response, error) {
+		return nil, httperrors.NewNotFound(req.Target)
+	}
+
+	testCases := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		next     func(req *request.Request) (*response.Response, error)
+		status   int
+		expected map[string]string
+	}{
+		{
+			name:    "Simple request",
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://app.example"},
+			next:    okHandler,
+			status:  200,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example",
+				"Access-Control-Allow-Credentials": "true",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:     "Disallowed origin",
+			method:   "GET",
+			headers:  map[string]string{"Origin": "https://evil.example"},
+			next:     okHandler,
+			status:   200,
+			expected: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "Preflight without OPTIONS route",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://app.example",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "Content-Type",
+			},
+			next:   notFound,
+			status: 204,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example",
+				"Access-Control-Allow-Methods": "PUT",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &request.Request{Method: tc.method, Target: "/items", Headers: tc.headers}
+			resp, err := CORS(opts)(tc.next)(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			for k, v := range tc.expected {
+				assert.Equal(t, v, resp.Headers[k], k)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// Gzip compresses response bodies for clients that accept gzip. It shows the
+// pattern for middleware that transforms a body: the returned resp.Body is
+// replaced with a reader producing the new bytes, and headers describing the
+// old body, such as Content-Length, are adjusted. The body is compressed as
+// the server writes it, so streaming bodies stay streaming. Responses written
+// directly through the ResponseWriter are left alone.
+func Gzip() Middleware {
+	return GzipWithOptions(GzipOptions{})
+}
+
+// GzipOptions configures GzipWithOptions.
+type GzipOptions struct {
+	// Level is the compression level, from gzip.BestSpeed to
+	// gzip.BestCompression, or gzip.DefaultCompression. Zero means
+	// gzip.DefaultCompression.
+	Level int
+
+	// MinLength leaves responses whose Content-Length is below it
+	// uncompressed, since compressing tiny bodies costs CPU and can enlarge
+	// them. Bodies of unknown length are always compressed.
+	MinLength int
+}
+
+// GzipWithOptions is like Gzip but configurable. It panics if opts.Level is
+// not a valid gzip level.
+func GzipWithOptions(opts GzipOptions) Middleware {
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		panic(fmt.Sprintf("middleware: Gzip: %v", err))
+	}
+
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			resp, err := next(req)
+			if err != nil || resp == nil || resp.Body == nil || req.Method == "HEAD" {
+				return resp, err
+			}
+			addVary(resp.Headers, "Accept-Encoding")
+			if !acceptsGzip(req.Headers["Accept-Encoding"]) || resp.Headers["Content-Encoding"] != "" {
+				return resp, nil
+			}
+			if length, err := strconv.Atoi(resp.Headers["Content-Length"]); err == nil && length < opts.MinLength {
+				return resp, nil
+			}
+
+			resp.Body = gzipBody(resp.Body, level)
+			delete(resp.Headers, "Content-Length")
+			resp.Headers["Content-Encoding"] = "gzip"
+			return resp, nil
+		}
+	}
+}
+
+// gzipBody returns a reader of the gzip-compressed contents of body. The
+// compression runs as the reader is consumed and stops when it is closed.
+func gzipBody(body io.Reader, level int) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		if closer, ok := body.(io.Closer); ok {
+			defer closer.Close()
+		}
+		gz, _ := gzip.NewWriterLevel(pw, level)
+		_, err := io.Copy(gz, body)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, entry := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, "gzip") && coding != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// addVary adds name to the Vary header unless it is already listed.
+func addVary(headers map[string]string, name string) {
+	vary := headers["Vary"]
+	for _, v := range strings.Split(vary, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), name) {
+			return
+		}
+	}
+	if vary == "" {
+		headers["Vary"] = name
+	} else {
+		headers["Vary"] = vary + ", " + name
+	}
+}
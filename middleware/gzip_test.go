@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzip(t *testing.T) {
+	payload := strings.Repeat("compress me ", 100)
+	handler := Gzip()(func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, payload)
+	})
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		compressed     bool
+	}{
+		{name: "Accepts gzip", acceptEncoding: "deflate, gzip", compressed: true},
+		{name: "Accepts anything", acceptEncoding: "*", compressed: true},
+		{name: "Refuses gzip", acceptEncoding: "gzip;q=0", compressed: false},
+		{name: "No Accept-Encoding", compressed: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &request.Request{Method: "GET", Headers: map[string]string{"Accept-Encoding": tc.acceptEncoding}}
+			resp, err := handler(req)
+			require.NoError(t, err)
+			assert.Equal(t, "Accept-Encoding", resp.Headers["Vary"])
+
+			if !tc.compressed {
+				assert.Empty(t, resp.Headers["Content-Encoding"])
+				assert.NotEmpty(t, resp.Headers["Content-Length"])
+				return
+			}
+			assert.Equal(t, "gzip", resp.Headers["Content-Encoding"])
+			assert.Empty(t, resp.Headers["Content-Length"], "the compressed length is not known up front")
+
+			gz, err := gzip.NewReader(resp.Body)
+			require.NoError(t, err)
+			body, err := io.ReadAll(gz)
+			require.NoError(t, err)
+			assert.Equal(t, payload, string(body))
+		})
+	}
+}
+
+func TestGzipLevel(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "record %d has weight %d\n", i, i*i%997)
+	}
+	payload := sb.String()
+
+	compressedSize := func(level int) int {
+		handler := GzipWithOptions(GzipOptions{Level: level})(func(req *request.Request) (*response.Response, error) {
+			return response.Text(200, payload)
+		})
+		resp, err := handler(&request.Request{Method: "GET", Headers: map[string]string{"Accept-Encoding": "gzip"}})
+		require.NoError(t, err)
+		compressed, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		require.NoError(t, err)
+		plain, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		assert.Equal(t, payload, string(plain))
+		return len(compressed)
+	}
+
+	assert.Less(t, compressedSize(gzip.BestCompression), compressedSize(gzip.BestSpeed))
+	assert.Panics(t, func() { GzipWithOptions(GzipOptions{Level: 42}) })
+}
+
+func TestGzipMinLength(t *testing.T) {
+	handler := GzipWithOptions(GzipOptions{MinLength: 100})(func(req *request.Request) (*response.Response, error) {
+		if req.Headers["X-Stream"] != "" {
+			return response.New(200, strings.NewReader("tiny")), nil
+		}
+		return response.Text(200, strings.Repeat("x", len(req.Target)))
+	})
+
+	testCases := []struct {
+		name       string
+		target     string
+		stream     bool
+		compressed bool
+	}{
+		{name: "Below threshold", target: "/short", compressed: false},
+		{name: "At threshold", target: "/" + strings.Repeat("a", 99), compressed: true},
+		{name: "Unknown length", target: "/", stream: true, compressed: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := map[string]string{"Accept-Encoding": "gzip"}
+			if tc.stream {
+				headers["X-Stream"] = "1"
+			}
+			resp, err := handler(&request.Request{Method: "GET", Target: tc.target, Headers: headers})
+			require.NoError(t, err)
+			assert.Equal(t, "Accept-Encoding", resp.Headers["Vary"])
+			if tc.compressed {
+				assert.Equal(t, "gzip", resp.Headers["Content-Encoding"])
+			} else {
+				assert.Empty(t, resp.Headers["Content-Encoding"])
+			}
+		})
+	}
+}
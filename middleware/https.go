@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// RedirectHTTPS redirects plaintext requests to the https:// URL with the same
+// host, path and query. A request is secure if it arrived over TLS, or if a
+// trusted TLS-terminating proxy reports https; see request.Request.Scheme.
+func RedirectHTTPS() Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			if req.Scheme() == "https" {
+				return next(req)
+			}
+			host := req.Host()
+			if host == "" {
+				return nil, httperrors.NewBadRequest("missing Host header")
+			}
+			// 308 keeps the method and body; 301 is better understood for GET.
+			status := 308
+			if req.Method == "GET" || req.Method == "HEAD" {
+				status = 301
+			}
+			return response.Redirect(status, "https://"+host+req.Target)
+		}
+	}
+}
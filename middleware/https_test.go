@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// okHandler is a downstream handler that always succeeds.
+func okHandler(req *request.Request) (*response.Response, error) {
+	return response.Text(200, "ok")
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	handler := RedirectHTTPS()(okHandler)
+
+	testCases := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		remoteAddr string
+		tls        bool
+		status     int
+		location   string
+	}{
+		{
+			name:     "Plaintext GET",
+			method:   "GET",
+			headers:  map[string]string{"Host": "example.com", "X-Forwarded-Proto": "http"},
+			status:   301,
+			location: "https://example.com/search?q=go",
+		},
+		{
+			name:     "Plaintext POST keeps method",
+			method:   "POST",
+			headers:  map[string]string{"Host": "example.com"},
+			status:   308,
+			location: "https://example.com/search?q=go",
+		},
+		{
+			name:       "Secure via trusted proxy",
+			method:     "GET",
+			headers:    map[string]string{"Host": "example.com", "X-Forwarded-Proto": "https"},
+			remoteAddr: "10.0.0.2:1234",
+			status:     200,
+		},
+		{
+			name:       "Spoofed header from untrusted peer",
+			method:     "GET",
+			headers:    map[string]string{"Host": "example.com", "X-Forwarded-Proto": "https"},
+			remoteAddr: "203.0.113.5:1234",
+			status:     301,
+			location:   "https://example.com/search?q=go",
+		},
+		{
+			name:    "Served over TLS",
+			method:  "GET",
+			headers: map[string]string{"Host": "example.com"},
+			tls:     true,
+			status:  200,
+		},
+	}
+	proxies, err := request.ParseNetworks([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &request.Request{
+				Method:         tc.method,
+				Target:         "/search?q=go",
+				Headers:        tc.headers,
+				RemoteAddr:     tc.remoteAddr,
+				TrustedProxies: proxies,
+			}
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			resp, err := handler(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.Equal(t, tc.location, resp.Headers["Location"])
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// IPFilter restricts access by client IP, as reported by Request.ClientIP, so
+// trusted proxies are looked through. Entries are IPs or CIDRs. Clients in
+// deny are refused with 403; if allow is non-empty, so is everyone outside
+// it. IPFilter panics if an entry is invalid, since that is a programming
+// error best caught at startup.
+func IPFilter(allow []string, deny []string) Middleware {
+	allowed, err := request.ParseNetworks(allow)
+	if err != nil {
+		panic(fmt.Sprintf("middleware: IPFilter allow list: %v", err))
+	}
+	denied, err := request.ParseNetworks(deny)
+	if err != nil {
+		panic(fmt.Sprintf("middleware: IPFilter deny list: %v", err))
+	}
+
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			ip := net.ParseIP(req.ClientIP())
+			switch {
+			case ip == nil:
+				return nil, httperrors.NewForbidden("client address unknown")
+			case request.ContainsIP(denied, ip):
+				return nil, httperrors.NewForbidden("client address denied")
+			case len(allowed) > 0 && !request.ContainsIP(allowed, ip):
+				return nil, httperrors.NewForbidden("client address not allowed")
+			}
+			return next(req)
+		}
+	}
+}
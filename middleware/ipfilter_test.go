@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilter(t *testing.T) {
+	handler := IPFilter([]string{"10.0.0.0/8", "192.0.2.7"}, []string{"10.0.13.0/24"})(okHandler)
+	proxies, err := request.ParseNetworks([]string{"172.16.0.1"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		status     int
+	}{
+		{name: "Allowed IP", remoteAddr: "192.0.2.7:4000", status: 200},
+		{name: "Allowed range", remoteAddr: "10.200.3.4:4000", status: 200},
+		{name: "Denied range inside allowed range", remoteAddr: "10.0.13.9:4000", status: 403},
+		{name: "Not allowed", remoteAddr: "198.51.100.1:4000", status: 403},
+		{name: "Client behind trusted proxy", remoteAddr: "172.16.0.1:4000", forwarded: "10.1.1.1", status: 200},
+		{name: "Denied client behind trusted proxy", remoteAddr: "172.16.0.1:4000", forwarded: "10.0.13.9", status: 403},
+		{name: "Spoofed header from untrusted peer", remoteAddr: "198.51.100.1:4000", forwarded: "10.1.1.1", status: 403},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &request.Request{
+				Method:         "GET",
+				Headers:        map[string]string{"X-Forwarded-For": tc.forwarded},
+				RemoteAddr:     tc.remoteAddr,
+				TrustedProxies: proxies,
+			}
+			resp, err := handler(req)
+			if tc.status == 200 {
+				require.NoError(t, err)
+				assert.Equal(t, 200, resp.StatusCode)
+				return
+			}
+			var httpErr *httperrors.HTTPError
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tc.status, httpErr.StatusCode)
+		})
+	}
+
+	assert.Panics(t, func() { IPFilter([]string{"not-an-ip"}, nil) })
+}
@@ -0,0 +1,28 @@
+// Package middleware provides handler wrappers for cross-cutting concerns
+// such as redirects, logging and access control.
+package middleware
+
+import "github.com/mohdrashid9678/rhttp/router"
+
+// Middleware wraps a handler to run code before and/or after it.
+type Middleware func(next router.Handler) router.Handler
+
+// Chain applies mws to handler so that the first middleware is the outermost.
+func Chain(handler router.Handler, mws ...Middleware) router.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+	return handler
+}
+
+// NamedMiddleware is a middleware carrying a name, so the server can report
+// its middleware stack for debugging. See rhttp.Server.UseNamed.
+type NamedMiddleware struct {
+	Name       string
+	Middleware Middleware
+}
+
+// Named gives mw a name.
+func Named(name string, mw Middleware) NamedMiddleware {
+	return NamedMiddleware{Name: name, Middleware: mw}
+}
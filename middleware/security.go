@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// SecurityHeadersOptions configures the SecurityHeaders middleware. The zero
+// value applies every header with its default; each can be changed or
+// switched off.
+type SecurityHeadersOptions struct {
+	// FrameOptions is the X-Frame-Options value. Empty means DENY.
+	FrameOptions string
+	// ReferrerPolicy is the Referrer-Policy value. Empty means
+	// strict-origin-when-cross-origin.
+	ReferrerPolicy string
+	// ContentSecurityPolicy is the Content-Security-Policy value. Empty
+	// means default-src 'self'.
+	ContentSecurityPolicy string
+	// HSTSMaxAge is how long browsers should insist on HTTPS, sent in
+	// Strict-Transport-Security. Zero means one year.
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains extends HSTS to every subdomain.
+	HSTSIncludeSubdomains bool
+
+	// The Disable fields leave the corresponding header out.
+	DisableContentTypeOptions    bool
+	DisableFrameOptions          bool
+	DisableReferrerPolicy        bool
+	DisableContentSecurityPolicy bool
+	DisableHSTS                  bool
+}
+
+// SecurityHeaders adds a bundle of security headers to responses:
+// X-Content-Type-Options: nosniff, X-Frame-Options, Referrer-Policy,
+// Content-Security-Policy and Strict-Transport-Security. Headers the handler
+// set itself are kept. Errors are passed through untouched, since the server
+// renders them after middleware has run; use Server.DefaultHeaders to cover
+// error responses too.
+func SecurityHeaders(opts SecurityHeadersOptions) Middleware {
+	headers := opts.headers()
+	return func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			resp, err := next(req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			for key, value := range headers {
+				if _, ok := resp.Headers[key]; !ok {
+					resp.Headers[key] = value
+				}
+			}
+			return resp, nil
+		}
+	}
+}
+
+// headers returns the headers opts asks for.
+func (opts SecurityHeadersOptions) headers() map[string]string {
+	headers := make(map[string]string)
+	if !opts.DisableContentTypeOptions {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	if !opts.DisableFrameOptions {
+		headers["X-Frame-Options"] = valueOr(opts.FrameOptions, "DENY")
+	}
+	if !opts.DisableReferrerPolicy {
+		headers["Referrer-Policy"] = valueOr(opts.ReferrerPolicy, "strict-origin-when-cross-origin")
+	}
+	if !opts.DisableContentSecurityPolicy {
+		headers["Content-Security-Policy"] = valueOr(opts.ContentSecurityPolicy, "default-src 'self'")
+	}
+	if !opts.DisableHSTS {
+		maxAge := opts.HSTSMaxAge
+		if maxAge == 0 {
+			maxAge = 365 * 24 * time.Hour
+		}
+		hsts := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+		if opts.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		headers["Strict-Transport-Security"] = hsts
+	}
+	return headers
+}
+
+// valueOr returns value, or fallback if value is empty.
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
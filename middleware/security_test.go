@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	resp, err := SecurityHeaders(SecurityHeadersOptions{})(okHandler)(&request.Request{Method: "GET", Headers: map[string]string{}})
+	require.NoError(t, err)
+	assert.Equal(t, "nosniff", resp.Headers["X-Content-Type-Options"])
+	assert.Equal(t, "DENY", resp.Headers["X-Frame-Options"])
+	assert.Equal(t, "strict-origin-when-cross-origin", resp.Headers["Referrer-Policy"])
+	assert.Equal(t, "default-src 'self'", resp.Headers["Content-Security-Policy"])
+	assert.Equal(t, "max-age=31536000", resp.Headers["Strict-Transport-Security"])
+}
+
+func TestSecurityHeadersOptions(t *testing.T) {
+	opts := SecurityHeadersOptions{
+		ContentSecurityPolicy: "default-src 'none'",
+		DisableHSTS:           true,
+		DisableFrameOptions:   true,
+	}
+	resp, err := SecurityHeaders(opts)(okHandler)(&request.Request{Method: "GET", Headers: map[string]string{}})
+	require.NoError(t, err)
+	assert.Equal(t, "default-src 'none'", resp.Headers["Content-Security-Policy"])
+	assert.NotContains(t, resp.Headers, "Strict-Transport-Security")
+	assert.NotContains(t, resp.Headers, "X-Frame-Options")
+	assert.Equal(t, "nosniff", resp.Headers["X-Content-Type-Options"])
+
+	opts = SecurityHeadersOptions{HSTSMaxAge: time.Hour, HSTSIncludeSubdomains: true}
+	resp, err = SecurityHeaders(opts)(okHandler)(&request.Request{Method: "GET", Headers: map[string]string{}})
+	require.NoError(t, err)
+	assert.Equal(t, "max-age=3600; includeSubDomains", resp.Headers["Strict-Transport-Security"])
+}
+
+func TestSecurityHeadersKeepsHandlerHeaders(t *testing.T) {
+	embeddable := func(req *request.Request) (*response.Response, error) {
+		resp, err := response.Text(200, "ok")
+		resp.Headers["X-Frame-Options"] = "SAMEORIGIN"
+		return resp, err
+	}
+	resp, err := SecurityHeaders(SecurityHeadersOptions{})(embeddable)(&request.Request{Method: "GET", Headers: map[string]string{}})
+	require.NoError(t, err)
+	assert.Equal(t, "SAMEORIGIN", resp.Headers["X-Frame-Options"])
+
+	failing := func(req *request.Request) (*response.Response, error) {
+		return nil, httperrors.NewNotFound(req.Target)
+	}
+	resp, err = SecurityHeaders(SecurityHeadersOptions{})(failing)(&request.Request{Method: "GET", Target: "/x", Headers: map[string]string{}})
+	assert.Nil(t, resp)
+	assert.Error(t, err)
+}
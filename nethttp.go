@@ -0,0 +1,207 @@
+package rhttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+)
+
+// FromHTTPHandler adapts a net/http handler, so existing http.Handler code
+// and stdlib middleware can be routed like any other handler. The handler's
+// output is buffered and returned as one response once it finishes, so
+// streaming through http.Flusher is not supported. Header fields it sets
+// more than once are joined with commas.
+func FromHTTPHandler(h http.Handler) router.Handler {
+	return func(req *request.Request) (*response.Response, error) {
+		httpReq, err := toHTTPRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		rec := &recorder{header: make(http.Header)}
+		h.ServeHTTP(rec, httpReq)
+		return rec.response(), nil
+	}
+}
+
+// toHTTPRequest builds the net/http equivalent of req. Its context is req's
+// context, so cancellation carries over.
+func toHTTPRequest(req *request.Request) (*http.Request, error) {
+	u, err := url.ParseRequestURI(req.Target)
+	if err != nil {
+		return nil, httperrors.NewBadRequest("invalid request target")
+	}
+	httpReq, err := http.NewRequestWithContext(req.Context(), req.Method, u.String(), req.Body)
+	if err != nil {
+		return nil, httperrors.NewBadRequest(err.Error())
+	}
+	httpReq.URL = u
+	httpReq.RequestURI = req.Target
+	httpReq.RemoteAddr = req.RemoteAddr
+	httpReq.TLS = req.TLS
+	if major, minor, ok := http.ParseHTTPVersion(req.Version); ok {
+		httpReq.Proto, httpReq.ProtoMajor, httpReq.ProtoMinor = req.Version, major, minor
+	}
+	for key, value := range req.Headers {
+		// Pass repeated fields on line by line, unless Headers has been
+		// changed since parsing.
+		if raw := req.RawHeaders[key]; len(raw) > 1 && value == strings.Join(raw, ", ") {
+			httpReq.Header[key] = append([]string(nil), raw...)
+			continue
+		}
+		httpReq.Header.Set(key, value)
+	}
+	httpReq.Host = req.Headers["Host"]
+	httpReq.ContentLength = -1
+	if length, err := strconv.ParseInt(req.Headers["Content-Length"], 10, 64); err == nil {
+		httpReq.ContentLength = length
+	}
+	if req.Body == nil || httpReq.ContentLength == 0 {
+		httpReq.Body = http.NoBody
+	}
+	return httpReq, nil
+}
+
+// recorder is the http.ResponseWriter handed to adapted handlers. It buffers
+// the response so it can be converted once the handler returns.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(statusCode int) {
+	if rec.status == 0 {
+		rec.status = statusCode
+	}
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(p)
+}
+
+// response converts what the handler wrote into a response.
+func (rec *recorder) response() *response.Response {
+	rec.WriteHeader(http.StatusOK)
+	resp := response.New(rec.status, bytes.NewReader(rec.body.Bytes()))
+	if resp.StatusText == "" {
+		resp.StatusText = http.StatusText(rec.status)
+	}
+	for key, values := range rec.header {
+		resp.Headers[key] = strings.Join(values, ", ")
+	}
+	if _, ok := resp.Headers["Content-Type"]; !ok && rec.body.Len() > 0 {
+		resp.Headers["Content-Type"] = http.DetectContentType(rec.body.Bytes())
+	}
+	resp.Headers["Content-Length"] = strconv.Itoa(rec.body.Len())
+	return resp
+}
+
+// HTTPHandler returns an http.Handler serving the server's routes, so they
+// can be mounted in a net/http server, e.g. for HTTP/2. Requests go through
+// the server's middleware, error rendering and OnError callbacks as usual.
+// Connection-level settings such as KeepAlive and MaxBodyBytes are left to
+// the net/http server, and response.WriterFromContext finds no writer, so
+// handlers must return their response. It panics if the server is
+// misconfigured, e.g. with an invalid CompressionLevel or error page.
+func (s *Server) HTTPHandler() http.Handler {
+	if _, err := s.builtinMiddleware(); err != nil {
+		panic(fmt.Sprintf("rhttp: HTTPHandler: %v", err))
+	}
+	if _, err := s.errorPages(); err != nil {
+		panic(fmt.Sprintf("rhttp: HTTPHandler: %v", err))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := fromHTTPRequest(r)
+		defer s.removeTempFiles(req)
+		req.TrustedProxies = s.TrustedProxies
+		req.ForwardedProtoHeader = s.ForwardedProtoHeader
+		if s.AllowMethodOverride {
+			overrideMethod(req)
+		}
+
+		resp, err := s.dispatch(req)
+		if err != nil {
+			if resp, err = s.errorResponse(err); err != nil {
+				s.logf("could not create error response: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+		s.addDefaultHeaders(resp.Headers)
+		if err := writeHTTPResponse(w, resp); err != nil {
+			s.logf("error writing response: %v", err)
+		}
+	})
+}
+
+// fromHTTPRequest converts a net/http request. The body is already decoded
+// by net/http, so no Transfer-Encoding is passed on.
+func fromHTTPRequest(r *http.Request) *request.Request {
+	req := &request.Request{
+		Method:     r.Method,
+		Target:     r.RequestURI,
+		Version:    r.Proto,
+		Headers:    make(map[string]string, len(r.Header)+1),
+		RawHeaders: make(map[string][]string, len(r.Header)+1),
+		Body:       r.Body,
+		PathParams: make(map[string]string),
+		RemoteAddr: r.RemoteAddr,
+		TLS:        r.TLS,
+	}
+	if req.Target == "" {
+		req.Target = r.URL.RequestURI()
+	}
+	req.Path, req.RawPath = r.URL.Path, r.URL.EscapedPath()
+	req.Query = r.URL.Query()
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+	for key, values := range r.Header {
+		separator := ", "
+		if key == "Cookie" {
+			separator = "; "
+		}
+		req.Headers[key] = strings.Join(values, separator)
+		req.RawHeaders[key] = append([]string(nil), values...)
+	}
+	req.Headers["Host"] = r.Host
+	req.RawHeaders["Host"] = []string{r.Host}
+	if _, ok := req.Headers["Content-Length"]; !ok && r.ContentLength > 0 {
+		req.Headers["Content-Length"] = strconv.FormatInt(r.ContentLength, 10)
+	}
+	return req.WithContext(r.Context())
+}
+
+// writeHTTPResponse sends resp through w. Framing headers are left to
+// net/http.
+func writeHTTPResponse(w http.ResponseWriter, resp *response.Response) error {
+	for key, value := range resp.Headers {
+		if key == "Connection" || key == "Transfer-Encoding" {
+			continue
+		}
+		w.Header().Set(key, value)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if resp.Body == nil {
+		return nil
+	}
+	if closer, ok := resp.Body.(io.Closer); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,94 @@
+package rhttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromHTTPHandler(t *testing.T) {
+	handler := FromHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Add("X-Seen", r.Method)
+		w.Header().Add("X-Seen", r.Header.Get("X-Token"))
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "%s %s q=%s body=%s", r.Host, r.URL.Path, r.URL.Query().Get("q"), body)
+	}))
+
+	req := &request.Request{
+		Method:  "POST",
+		Target:  "/items?q=go",
+		Version: "HTTP/1.1",
+		Headers: map[string]string{"Host": "example.com", "X-Token": "abc", "Content-Length": "5"},
+		Body:    io.NopCloser(strings.NewReader("hello")),
+	}
+	resp, err := handler(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, 202, resp.StatusCode)
+	assert.Equal(t, "Accepted", resp.StatusText)
+	assert.Equal(t, "text/plain", resp.Headers["Content-Type"])
+	assert.Equal(t, "POST, abc", resp.Headers["X-Seen"])
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com /items q=go body=hello", string(body))
+	assert.Equal(t, fmt.Sprint(len(body)), resp.Headers["Content-Length"])
+
+	t.Run("Implicit 200", func(t *testing.T) {
+		handler := FromHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "<p>hi</p>")
+		}))
+		s := New(":0")
+		s.AddRoute("GET", "/", handler)
+		out := roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"), out)
+		assert.Contains(t, out, "Content-Type: text/html; charset=utf-8\r\n")
+		assert.True(t, strings.HasSuffix(out, "\r\n\r\n<p>hi</p>"))
+	})
+}
+
+func TestHTTPHandler(t *testing.T) {
+	s := New(":0")
+	s.Use(func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			resp, err := next(req)
+			if resp != nil {
+				resp.Headers["X-Middleware"] = "ran"
+			}
+			return resp, err
+		}
+	})
+	s.AddRoute("POST", "/users/:id", func(req *request.Request) (*response.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(201, fmt.Sprintf("user %s got %q via %s", req.PathParams["id"], body, req.Headers["Host"]))
+	})
+	server := httptest.NewServer(s.HTTPHandler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/users/7", "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "ran", resp.Header.Get("X-Middleware"))
+	assert.Equal(t, fmt.Sprintf("user 7 got \"hello\" via %s", strings.TrimPrefix(server.URL, "http://")), string(body))
+
+	resp, err = http.Get(server.URL + "/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 404, resp.StatusCode)
+}
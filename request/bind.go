@@ -0,0 +1,88 @@
+package request
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// BindJSON decodes the JSON body into v. The body must hold exactly one JSON
+// value: concatenated or trailing data is rejected with a 400, while trailing
+// whitespace is accepted.
+func (r *Request) BindJSON(v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(v); err != nil {
+		return httperrors.NewBadRequest(describeJSONError(err))
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return httperrors.NewBadRequest("unexpected data after JSON body")
+	}
+	return nil
+}
+
+// describeJSONError explains a decoding failure in terms the client can act
+// on: where the syntax broke, or which field had the wrong type. Other errors
+// get a generic message so no internal detail leaks.
+func describeJSONError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "malformed JSON: unexpected end of body"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("malformed JSON at byte offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		want, got := jsonKind(typeErr.Type), typeErr.Value
+		if got == "bool" {
+			got = "boolean"
+		}
+		if typeErr.Field == "" {
+			return fmt.Sprintf("JSON body must be %s, got %s", want, got)
+		}
+		return fmt.Sprintf("field '%s' must be %s, got %s", typeErr.Field, want, got)
+	default:
+		return "invalid JSON body"
+	}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// jsonKind names the kind of JSON value that decodes into t, so error
+// messages describe the expected input rather than Go types. The name
+// comes with its article, as in "an object".
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "a string" // Byte slices are base64-encoded.
+		}
+		return "an array"
+	case reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a valid value"
+	}
+}
@@ -0,0 +1,76 @@
+package request
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindJSON(t *testing.T) {
+	testCases := []struct {
+		name      string
+		body      string
+		expectErr bool
+		expected  map[string]int
+	}{
+		{name: "Single object", body: `{"a":1}`, expected: map[string]int{"a": 1}},
+		{name: "Trailing whitespace", body: "{\"a\":1}  \r\n\t", expected: map[string]int{"a": 1}},
+		{name: "Concatenated objects", body: `{"a":1}{"b":2}`, expectErr: true},
+		{name: "Trailing garbage", body: `{"a":1} x`, expectErr: true},
+		{name: "Malformed JSON", body: `{"a":`, expectErr: true},
+		{name: "Empty body", body: "", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{Body: io.NopCloser(strings.NewReader(tc.body))}
+			var got map[string]int
+			err := req.BindJSON(&got)
+			if tc.expectErr {
+				var httpErr *httperrors.HTTPError
+				require.ErrorAs(t, err, &httpErr)
+				assert.Equal(t, 400, httpErr.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestBindJSONErrorMessages(t *testing.T) {
+	type user struct {
+		Name    string `json:"name"`
+		Age     int    `json:"age"`
+		Address struct {
+			Zip int `json:"zip"`
+		} `json:"address"`
+	}
+
+	testCases := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{name: "Type mismatch", body: `{"name":"a","age":"thirty"}`, message: "field 'age' must be a number, got string"},
+		{name: "Nested type mismatch", body: `{"address":{"zip":true}}`, message: "field 'address.zip' must be a number, got boolean"},
+		{name: "Wrong top-level type", body: `[1,2]`, message: "JSON body must be an object, got array"},
+		{name: "Syntax error", body: `{"name":"a",,}`, message: "malformed JSON at byte offset 13"},
+		{name: "Truncated body", body: `{"name":"a"`, message: "malformed JSON: unexpected end of body"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{Body: io.NopCloser(strings.NewReader(tc.body))}
+			var u user
+			err := req.BindJSON(&u)
+
+			var httpErr *httperrors.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			assert.Equal(t, 400, httpErr.StatusCode)
+			assert.Equal(t, tc.message, httpErr.Message)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package request
+
+import (
+	"bytes"
+	"io"
+)
+
+// cachedBody is a request body held in memory so it can be read repeatedly.
+type cachedBody struct {
+	data   []byte
+	reader *bytes.Reader
+}
+
+// Read reads from the cached body, rewinding to the start once it reaches the
+// end so the next reader sees the full body again.
+func (b *cachedBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if err == io.EOF {
+		b.reader.Reset(b.data)
+	}
+	return n, err
+}
+
+func (b *cachedBody) Close() error {
+	b.reader.Reset(b.data)
+	return nil
+}
+
+// CacheBody reads the rest of the body into memory and makes Body
+// re-readable, so middleware can inspect it without starving the handler.
+// The body is bounded by its declared Content-Length.
+func (r *Request) CacheBody() error {
+	if _, ok := r.Body.(*cachedBody); ok {
+		return nil
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = &cachedBody{data: data, reader: bytes.NewReader(data)}
+	return nil
+}
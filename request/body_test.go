@@ -0,0 +1,33 @@
+package request
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheBody(t *testing.T) {
+	const payload = `{"username":"test"}`
+	req := &Request{Body: io.NopCloser(strings.NewReader(payload))}
+
+	require.NoError(t, req.CacheBody())
+	require.NoError(t, req.CacheBody(), "caching twice should be a no-op")
+
+	for i := 0; i < 2; i++ {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, payload, string(body), "read %d should return the full body", i+1)
+	}
+
+	// A partial read followed by Close also starts over.
+	buf := make([]byte, 4)
+	_, err := req.Body.Read(buf)
+	require.NoError(t, err)
+	require.NoError(t, req.Body.Close())
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(body))
+}
@@ -0,0 +1,100 @@
+package request
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// chunkedReader decodes a body sent with the chunked transfer coding (RFC
+// 9112, section 7.1). The trailer section after the last chunk is stored in
+// req.Trailers.
+type chunkedReader struct {
+	r         *bufio.Reader
+	req       *Request
+	limit     int64 // Maximum total body size; zero means no limit.
+	read      int64
+	remaining int64 // Bytes left in the current chunk.
+	err       error
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if cr.remaining == 0 {
+		if cr.err = cr.nextChunk(); cr.err != nil {
+			return 0, cr.err
+		}
+	}
+
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.r.Read(p)
+	cr.remaining -= int64(n)
+	if err == io.EOF {
+		err = ErrBodyTruncated
+	}
+	if err == nil && cr.remaining == 0 {
+		err = cr.readCRLF()
+	}
+	if err != nil {
+		cr.err = err
+	}
+	return n, err
+}
+
+// nextChunk reads a chunk-size line. At the last chunk it reads the trailers
+// and returns io.EOF.
+func (cr *chunkedReader) nextChunk() error {
+	line, err := readLine(cr.r, maxLineLength, httperrors.NewBadRequest("chunk size line too long"))
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	sizeStr, _, _ := strings.Cut(string(line), ";") // Chunk extensions are ignored.
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 {
+		return httperrors.NewBadRequest("invalid chunk size")
+	}
+	if size == 0 {
+		trailers := make(map[string]string)
+		if _, err := parseHeaders(cr.r, trailers, nil, nil, DefaultMaxHeaderBytes); err != nil {
+			return unexpectedEOF(err)
+		}
+		cr.req.Trailers = trailers
+		return io.EOF
+	}
+
+	cr.read += size
+	if cr.limit > 0 && cr.read > cr.limit {
+		return httperrors.NewPayloadTooLarge(cr.limit)
+	}
+	cr.remaining = size
+	return nil
+}
+
+// readCRLF consumes the line break that ends a chunk's data.
+func (cr *chunkedReader) readCRLF() error {
+	var crlf [2]byte
+	if _, err := io.ReadFull(cr.r, crlf[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if string(crlf[:]) != "\r\n" {
+		return httperrors.NewBadRequest("malformed chunk")
+	}
+	return nil
+}
+
+// unexpectedEOF turns an end of input inside a chunked body into
+// ErrBodyTruncated, since the body has not been terminated properly.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrBodyTruncated
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkedBodyWithTrailers(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		defer clientConn.Close()
+		clientConn.Write([]byte("POST /upload HTTP/1.1\r\nTransfer-Encoding: chunked\r\nTrailer: Checksum\r\n\r\n" +
+			"5\r\nhello\r\n" +
+			"6;ext=1\r\n world\r\n" +
+			"0\r\nChecksum: abc123\r\n\r\n" +
+			"GET /next HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	}()
+
+	reader := NewReader(serverConn)
+	req, err := reader.Next()
+	require.NoError(t, err)
+	assert.Nil(t, req.Trailers, "trailers arrive after the body")
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+	assert.Equal(t, map[string]string{"Checksum": "abc123"}, req.Trailers)
+
+	next, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "/next", next.Target)
+}
+
+func TestChunkedBodyErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		raw      string
+		maxBytes int64
+		check    func(t *testing.T, err error)
+	}{
+		{
+			name: "Truncated",
+			raw:  "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhel",
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrBodyTruncated)
+			},
+		},
+		{
+			name: "Invalid size",
+			raw:  "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n",
+			check: func(t *testing.T, err error) {
+				var httpErr *httperrors.HTTPError
+				require.True(t, errors.As(err, &httpErr))
+				assert.Equal(t, 400, httpErr.StatusCode)
+			},
+		},
+		{
+			name:     "Too large",
+			raw:      "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n",
+			maxBytes: 8,
+			check: func(t *testing.T, err error) {
+				var httpErr *httperrors.HTTPError
+				require.True(t, errors.As(err, &httpErr))
+				assert.Equal(t, 413, httpErr.StatusCode)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			go func() {
+				clientConn.Write([]byte(tc.raw))
+				clientConn.Close()
+			}()
+
+			reader := NewReader(serverConn)
+			reader.MaxBodyBytes = tc.maxBytes
+			req, err := reader.Next()
+			require.NoError(t, err)
+			_, err = io.ReadAll(req.Body)
+			tc.check(t, err)
+		})
+	}
+}
+
+func TestTransferEncodingCodings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		encoding string
+		body     string
+		status   int
+	}{
+		{name: "Identity uses Content-Length", encoding: "identity", body: "2\r\nhi"},
+		{name: "Identity before chunked", encoding: "identity, chunked", body: "hi"},
+		{name: "Content coding", encoding: "gzip, chunked", status: 501},
+		{name: "Unknown coding", encoding: "foo", status: 501},
+		{name: "Chunked not last", encoding: "chunked, identity, chunked", status: 400},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			go func() {
+				defer clientConn.Close()
+				clientConn.Write([]byte("POST / HTTP/1.1\r\nTransfer-Encoding: " + tc.encoding +
+					"\r\nContent-Length: 5\r\n\r\n" + "2\r\nhi\r\n0\r\n\r\n"))
+			}()
+
+			req, err := NewReader(serverConn).Next()
+			if tc.status != 0 {
+				var httpErr *httperrors.HTTPError
+				require.True(t, errors.As(err, &httpErr))
+				assert.Equal(t, tc.status, httpErr.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(body))
+		})
+	}
+}
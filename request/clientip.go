@@ -0,0 +1,78 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseNetworks parses CIDRs such as "10.0.0.0/8" into networks. A bare IP is
+// taken as a network holding just that address.
+func ParseNetworks(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address '%s'", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s': %w", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ContainsIP reports whether ip is in any of networks.
+func ContainsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that made the request. When the
+// peer is one of TrustedProxies, X-Forwarded-For is followed back past the
+// trusted hops to the first address not in the list; otherwise the header is
+// ignored, since any client can send it.
+func (r *Request) ClientIP() string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !r.trusts(peer) {
+		return peer
+	}
+
+	hops := strings.Split(r.Headers["X-Forwarded-For"], ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !r.trusts(hop) {
+			return hop
+		}
+		peer = hop
+	}
+	// Every hop is trusted; the earliest one is the best guess.
+	return peer
+}
+
+// trusts reports whether addr belongs to a trusted proxy.
+func (r *Request) trusts(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ContainsIP(r.TrustedProxies, ip)
+}
@@ -0,0 +1,40 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseNetworks([]string{"10.0.0.0/8", "2001:db8::1"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{name: "Direct client", remoteAddr: "203.0.113.5:1234", expected: "203.0.113.5"},
+		{name: "Header from untrusted peer ignored", remoteAddr: "203.0.113.5:1234", forwarded: "198.51.100.1", expected: "203.0.113.5"},
+		{name: "Trusted proxy", remoteAddr: "10.0.0.2:1234", forwarded: "198.51.100.1", expected: "198.51.100.1"},
+		{name: "Chain of trusted proxies", remoteAddr: "10.0.0.2:1234", forwarded: "1.2.3.4, 198.51.100.1, 10.9.9.9", expected: "198.51.100.1"},
+		{name: "IPv6 proxy", remoteAddr: "[2001:db8::1]:443", forwarded: "198.51.100.1", expected: "198.51.100.1"},
+		{name: "Trusted proxy without header", remoteAddr: "10.0.0.2:1234", expected: "10.0.0.2"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{
+				Headers:        map[string]string{"X-Forwarded-For": tc.forwarded},
+				RemoteAddr:     tc.remoteAddr,
+				TrustedProxies: proxies,
+			}
+			assert.Equal(t, tc.expected, req.ClientIP())
+		})
+	}
+
+	_, err = ParseNetworks([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package request
+
+import (
+	"strings"
+	"time"
+)
+
+// httpTimeFormat is the IMF-fixdate format used by HTTP date headers.
+const httpTimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// CheckPrecondition evaluates the request's conditional headers against the
+// target resource's current ETag and modification time, in the order given by
+// RFC 9110, section 13.2.2. A zero lastModified skips the date checks. When ok
+// is false, status is the code to respond with: 412 Precondition Failed, or
+// 304 Not Modified for a GET or HEAD that the client already has cached.
+func (r *Request) CheckPrecondition(currentETag string, lastModified time.Time) (ok bool, status int) {
+	lastModified = lastModified.Truncate(time.Second)
+
+	if ifMatch, has := r.Headers["If-Match"]; has {
+		if !etagListMatches(ifMatch, currentETag, true) {
+			return false, 412
+		}
+	} else if since, has := r.dateHeader("If-Unmodified-Since"); has && !lastModified.IsZero() {
+		if lastModified.After(since) {
+			return false, 412
+		}
+	}
+
+	safe := r.Method == "GET" || r.Method == "HEAD"
+	if ifNoneMatch, has := r.Headers["If-None-Match"]; has {
+		if etagListMatches(ifNoneMatch, currentETag, false) {
+			if safe {
+				return false, 304
+			}
+			return false, 412
+		}
+	} else if since, has := r.dateHeader("If-Modified-Since"); has && safe && !lastModified.IsZero() {
+		if !lastModified.After(since) {
+			return false, 304
+		}
+	}
+	return true, 0
+}
+
+// dateHeader parses an HTTP date header, reporting false if it is absent or invalid.
+func (r *Request) dateHeader(name string) (time.Time, bool) {
+	v, has := r.Headers[name]
+	if !has {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(httpTimeFormat, v)
+	return t, err == nil
+}
+
+// etagListMatches reports whether the comma-separated entity tags in list
+// match current. Strong comparison rejects weak tags on either side.
+func etagListMatches(list, current string, strong bool) bool {
+	if current == "" {
+		return false
+	}
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strong {
+			if tag == current && !strings.HasPrefix(tag, "W/") {
+				return true
+			}
+		} else if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(current, "W/") {
+			return true
+		}
+	}
+	return false
+}
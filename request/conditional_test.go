@@ -0,0 +1,55 @@
+package request
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPrecondition(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const etag = `"v2"`
+
+	testCases := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		ok      bool
+		status  int
+	}{
+		{name: "No conditions", method: "PUT", headers: map[string]string{}, ok: true},
+		{name: "If-Match matches", method: "PUT", headers: map[string]string{"If-Match": `"v1", "v2"`}, ok: true},
+		{name: "If-Match does not match", method: "PUT", headers: map[string]string{"If-Match": `"v1"`}, status: 412},
+		{name: "If-Match wildcard", method: "DELETE", headers: map[string]string{"If-Match": "*"}, ok: true},
+		{name: "If-Match rejects weak tag", method: "PUT", headers: map[string]string{"If-Match": `W/"v2"`}, status: 412},
+		{
+			name:    "If-Unmodified-Since passes",
+			method:  "PUT",
+			headers: map[string]string{"If-Unmodified-Since": "Wed, 01 May 2024 12:00:00 GMT"},
+			ok:      true,
+		},
+		{
+			name:    "If-Unmodified-Since fails",
+			method:  "PUT",
+			headers: map[string]string{"If-Unmodified-Since": "Tue, 30 Apr 2024 12:00:00 GMT"},
+			status:  412,
+		},
+		{name: "If-None-Match on GET", method: "GET", headers: map[string]string{"If-None-Match": `W/"v2"`}, status: 304},
+		{name: "If-None-Match on PUT", method: "PUT", headers: map[string]string{"If-None-Match": "*"}, status: 412},
+		{
+			name:    "If-Modified-Since not modified",
+			method:  "GET",
+			headers: map[string]string{"If-Modified-Since": "Wed, 01 May 2024 12:00:00 GMT"},
+			status:  304,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{Method: tc.method, Headers: tc.headers}
+			ok, status := req.CheckPrecondition(etag, modified)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.status, status)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package request
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// digestAlgorithms are the Digest header algorithms VerifyDigest can check.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// VerifyDigest checks the body against its Content-MD5 or Digest header and
+// returns a 400 on mismatch. It caches the body first (see CacheBody), so the
+// handler can still read it afterwards. Requests carrying neither header pass.
+func (r *Request) VerifyDigest() error {
+	contentMD5, digest := r.Headers["Content-Md5"], r.Headers["Digest"]
+	if contentMD5 == "" && digest == "" {
+		return nil
+	}
+	if err := r.CacheBody(); err != nil {
+		return err
+	}
+	data := r.Body.(*cachedBody).data
+
+	if contentMD5 != "" && !digestMatches(md5.New, data, contentMD5) {
+		return httperrors.NewBadRequest("body does not match Content-MD5")
+	}
+	if digest == "" {
+		return nil
+	}
+	checked := false
+	for _, entry := range strings.Split(digest, ",") {
+		alg, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		newHash, known := digestAlgorithms[strings.ToLower(alg)]
+		if !ok || !known {
+			continue
+		}
+		if !digestMatches(newHash, data, value) {
+			return httperrors.NewBadRequest(fmt.Sprintf("body does not match %s digest", alg))
+		}
+		checked = true
+	}
+	if !checked {
+		return httperrors.NewBadRequest("no supported algorithm in Digest header")
+	}
+	return nil
+}
+
+// digestMatches reports whether the base64-encoded expected digest is the
+// hash of data.
+func digestMatches(newHash func() hash.Hash, data []byte, expected string) bool {
+	want, err := base64.StdEncoding.DecodeString(expected)
+	if err != nil {
+		return false
+	}
+	h := newHash()
+	h.Write(data)
+	return subtle.ConstantTimeCompare(h.Sum(nil), want) == 1
+}
@@ -0,0 +1,46 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDigest(t *testing.T) {
+	const body = "hello world"
+	testCases := []struct {
+		name      string
+		headers   map[string]string
+		expectErr bool
+	}{
+		{name: "Matching Content-MD5", headers: map[string]string{"Content-Md5": "XrY7u+Ae7tCTyyK7j1rNww=="}},
+		{name: "Matching Digest", headers: map[string]string{"Digest": "SHA-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="}},
+		{name: "Unknown algorithm skipped", headers: map[string]string{"Digest": "crc32c=abc, sha-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="}},
+		{name: "No digest headers", headers: map[string]string{}},
+		{name: "Mismatched Content-MD5", headers: map[string]string{"Content-Md5": "AAAAAAAAAAAAAAAAAAAAAA=="}, expectErr: true},
+		{name: "Mismatched Digest", headers: map[string]string{"Digest": "sha-256=XrY7u+Ae7tCTyyK7j1rNww=="}, expectErr: true},
+		{name: "Only unsupported algorithms", headers: map[string]string{"Digest": "crc32c=abc"}, expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{Headers: tc.headers, Body: io.NopCloser(strings.NewReader(body))}
+			err := req.VerifyDigest()
+			if tc.expectErr {
+				var httpErr *httperrors.HTTPError
+				require.True(t, errors.As(err, &httpErr))
+				assert.Equal(t, 400, httpErr.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+
+			data, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(data), "the handler can still read the body")
+		})
+	}
+}
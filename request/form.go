@@ -0,0 +1,76 @@
+package request
+
+import (
+	"io"
+	"mime"
+	"net/url"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// MaxFormBytes bounds the application/x-www-form-urlencoded body PostForm
+// reads, and DefaultMaxFormMemory is the memory budget FormValue gives
+// MultipartForm for multipart bodies.
+const (
+	MaxFormBytes         = 10 << 20
+	DefaultMaxFormMemory = 32 << 20
+)
+
+// PostForm parses the form fields in the body: an
+// application/x-www-form-urlencoded body, or the non-file fields of a
+// multipart/form-data one. Other bodies have no fields, which is not an
+// error. A urlencoded body over MaxFormBytes is a 413 and a malformed one a
+// 400. The body is parsed once; later calls return the same result.
+func (r *Request) PostForm() (map[string][]string, error) {
+	if r.uploads == nil {
+		r.uploads = &uploads{}
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Headers["Content-Type"])
+	if mediaType == "multipart/form-data" {
+		form, err := r.MultipartForm(DefaultMaxFormMemory)
+		if err != nil {
+			return nil, err
+		}
+		return form.Value, nil
+	}
+
+	r.uploads.mu.Lock()
+	defer r.uploads.mu.Unlock()
+	if r.uploads.postForm == nil && r.uploads.postFormErr == nil {
+		r.uploads.postForm, r.uploads.postFormErr = r.parseURLEncoded(mediaType)
+	}
+	return r.uploads.postForm, r.uploads.postFormErr
+}
+
+// parseURLEncoded reads the fields of an application/x-www-form-urlencoded
+// body.
+func (r *Request) parseURLEncoded(mediaType string) (map[string][]string, error) {
+	if mediaType != "application/x-www-form-urlencoded" || r.Body == nil {
+		return url.Values{}, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxFormBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFormBytes {
+		return nil, httperrors.NewPayloadTooLarge(MaxFormBytes)
+	}
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, httperrors.NewBadRequest("malformed form body")
+	}
+	return values, nil
+}
+
+// FormValue returns the first value of the form field key, looking in the
+// body's fields before the query string, so one lookup serves both. Errors
+// parsing the body are ignored, leaving only the query; call PostForm to see
+// them.
+func (r *Request) FormValue(key string) string {
+	if form, err := r.PostForm(); err == nil {
+		if v := form[key]; len(v) > 0 {
+			return v[0]
+		}
+	}
+	return r.QueryParam(key)
+}
@@ -0,0 +1,84 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// formRequest returns a POST to target with a urlencoded body.
+func formRequest(target, body string) *Request {
+	return &Request{
+		Method:  "POST",
+		Target:  target,
+		Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		Body:    io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestFormValue(t *testing.T) {
+	req := formRequest("/signup?plan=free&ref=ad&source=query", "name=Ada+Lovelace&plan=pro&source=")
+
+	assert.Equal(t, "ad", req.FormValue("ref"), "query only")
+	assert.Equal(t, "Ada Lovelace", req.FormValue("name"), "body only")
+	assert.Equal(t, "pro", req.FormValue("plan"), "body wins over query")
+	assert.Equal(t, "", req.FormValue("source"), "an empty body value still wins")
+	assert.Equal(t, "", req.FormValue("missing"))
+
+	// The body was consumed by the first call; the fields are kept.
+	form, err := req.PostForm()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pro"}, form["plan"])
+
+	// Copies share the parsed form.
+	assert.Equal(t, "pro", req.WithContext(req.Context()).FormValue("plan"))
+}
+
+func TestFormValueMultipart(t *testing.T) {
+	req := multipartRequest(t, map[string]string{"plan": "pro"}, map[string]string{"avatar": "png"})
+	req.Target = "/signup?plan=free&ref=ad"
+	defer req.RemoveTempFiles()
+
+	assert.Equal(t, "pro", req.FormValue("plan"))
+	assert.Equal(t, "ad", req.FormValue("ref"))
+	assert.Equal(t, "", req.FormValue("avatar"), "files are not form values")
+}
+
+func TestPostForm(t *testing.T) {
+	t.Run("Other body", func(t *testing.T) {
+		req := &Request{
+			Method:  "POST",
+			Target:  "/?q=go",
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    io.NopCloser(strings.NewReader(`{"q":"rust"}`)),
+		}
+		form, err := req.PostForm()
+		require.NoError(t, err)
+		assert.Empty(t, form)
+		assert.Equal(t, "go", req.FormValue("q"))
+	})
+
+	t.Run("Malformed body", func(t *testing.T) {
+		req := formRequest("/?q=go", "q=%zz")
+		_, err := req.PostForm()
+		var httpErr *httperrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, 400, httpErr.StatusCode)
+		assert.Equal(t, "go", req.FormValue("q"))
+		_, again := req.PostForm()
+		assert.Equal(t, err, again)
+	})
+
+	t.Run("Body too large", func(t *testing.T) {
+		req := formRequest("/", "q="+strings.Repeat("a", MaxFormBytes))
+		_, err := req.PostForm()
+		var httpErr *httperrors.HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		assert.Equal(t, 413, httpErr.StatusCode)
+	})
+}
@@ -0,0 +1,75 @@
+package request
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// weightedValue is one entry of a header such as Accept-Language.
+type weightedValue struct {
+	value string
+	q     float64
+}
+
+// parseWeighted parses a comma-separated header with optional q-values,
+// ordered from most to least preferred. Entries with q=0 are dropped.
+func parseWeighted(header string) []weightedValue {
+	var values []weightedValue
+	for _, entry := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(entry, ";")
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			values = append(values, weightedValue{value: value, q: q})
+		}
+	}
+	// A stable sort keeps the client's order among equal weights.
+	sort.SliceStable(values, func(i, j int) bool { return values[i].q > values[j].q })
+	return values
+}
+
+// AcceptLanguage returns the entry of supported that best matches the
+// Accept-Language header, or "" if none does. Tags match exactly, or by
+// language when one side has no region, so "en" matches "en-US" and the
+// other way round; an exact match is preferred. A wildcard picks the first
+// supported tag.
+func (r *Request) AcceptLanguage(supported []string) string {
+	for _, pref := range parseWeighted(r.Headers["Accept-Language"]) {
+		if pref.value == "*" {
+			if len(supported) > 0 {
+				return supported[0]
+			}
+			continue
+		}
+		for _, tag := range supported {
+			if strings.EqualFold(tag, pref.value) {
+				return tag
+			}
+		}
+		for _, tag := range supported {
+			if languageMatches(pref.value, tag) {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
+// languageMatches reports whether tags a and b share a language and at least
+// one of them leaves the region open.
+func languageMatches(a, b string) bool {
+	langA, regionA, _ := strings.Cut(a, "-")
+	langB, regionB, _ := strings.Cut(b, "-")
+	return strings.EqualFold(langA, langB) && (regionA == "" || regionB == "")
+}
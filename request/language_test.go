@@ -0,0 +1,33 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptLanguage(t *testing.T) {
+	supported := []string{"en-US", "fr", "de-DE"}
+	testCases := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{name: "Exact match", header: "fr", expected: "fr"},
+		{name: "Weighted preferences", header: "de-DE;q=0.5, fr;q=0.9, en-US;q=0.7", expected: "fr"},
+		{name: "Language matches region", header: "en", expected: "en-US"},
+		{name: "Region matches language", header: "fr-CA, de;q=0.8", expected: "fr"},
+		{name: "Different regions do not match", header: "en-GB, de;q=0.5", expected: "de-DE"},
+		{name: "Case insensitive", header: "EN-us", expected: "en-US"},
+		{name: "Excluded with q=0", header: "fr;q=0, de-DE;q=0.1", expected: "de-DE"},
+		{name: "Wildcard", header: "ja, *;q=0.1", expected: "en-US"},
+		{name: "No match", header: "ja, zh-CN", expected: ""},
+		{name: "Missing header", header: "", expected: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{Headers: map[string]string{"Accept-Language": tc.header}}
+			assert.Equal(t, tc.expected, req.AcceptLanguage(supported))
+		})
+	}
+}
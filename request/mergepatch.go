@@ -0,0 +1,68 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7386) to target, which must
+// be a non-nil pointer to a value that round-trips through encoding/json.
+// Fields in patch replace those in target, nested objects are merged, and
+// null removes a field, leaving it at its zero value. A malformed patch, or
+// one that does not fit target's type, is a 400 and leaves target unchanged.
+func ApplyMergePatch(target interface{}, patch []byte) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("merge patch target must be a non-nil pointer, got %T", target)
+	}
+
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return httperrors.NewBadRequest(describeJSONError(err))
+	}
+	current, err := json.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("failed to encode merge patch target: %w", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return fmt.Errorf("failed to decode merge patch target: %w", err)
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patchDoc))
+	if err != nil {
+		return fmt.Errorf("failed to encode merged document: %w", err)
+	}
+	// Decode into a fresh value so removed fields do not keep their old
+	// values, and so target is left untouched if decoding fails.
+	elem := rv.Elem()
+	fresh := reflect.New(elem.Type())
+	if err := json.Unmarshal(merged, fresh.Interface()); err != nil {
+		return httperrors.NewBadRequest(describeJSONError(err))
+	}
+	elem.Set(fresh.Elem())
+	return nil
+}
+
+// mergePatch implements the MergePatch function of RFC 7386, section 2.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+	for name, value := range patchObj {
+		if value == nil {
+			delete(targetObj, name)
+			continue
+		}
+		targetObj[name] = mergePatch(targetObj[name], value)
+	}
+	return targetObj
+}
@@ -0,0 +1,81 @@
+package request
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type profile struct {
+	Name    string   `json:"name"`
+	Email   string   `json:"email,omitempty"`
+	Age     int      `json:"age"`
+	Tags    []string `json:"tags"`
+	Address *address `json:"address,omitempty"`
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	original := func() *profile {
+		return &profile{
+			Name:    "Ada",
+			Email:   "ada@example.com",
+			Age:     36,
+			Tags:    []string{"math", "engines"},
+			Address: &address{City: "London", Country: "UK"},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		patch    string
+		expected *profile
+	}{
+		{
+			name:  "Updates only given fields",
+			patch: `{"age": 37, "address": {"city": "Paris"}}`,
+			expected: &profile{Name: "Ada", Email: "ada@example.com", Age: 37, Tags: []string{"math", "engines"},
+				Address: &address{City: "Paris", Country: "UK"}},
+		},
+		{
+			name:  "Null removes fields",
+			patch: `{"email": null, "address": {"country": null}}`,
+			expected: &profile{Name: "Ada", Age: 36, Tags: []string{"math", "engines"},
+				Address: &address{City: "London"}},
+		},
+		{
+			name:     "Arrays are replaced",
+			patch:    `{"tags": ["poetry"], "address": null}`,
+			expected: &profile{Name: "Ada", Email: "ada@example.com", Age: 36, Tags: []string{"poetry"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := original()
+			require.NoError(t, ApplyMergePatch(p, []byte(tc.patch)))
+			assert.Equal(t, tc.expected, p)
+		})
+	}
+
+	t.Run("Invalid patches", func(t *testing.T) {
+		for _, patch := range []string{`{"age": `, `{"age": "old"}`} {
+			p := original()
+			err := ApplyMergePatch(p, []byte(patch))
+			var httpErr *httperrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), patch)
+			assert.Equal(t, 400, httpErr.StatusCode)
+			assert.Equal(t, original(), p, "target must be unchanged after %s", patch)
+		}
+	})
+
+	t.Run("Target must be a pointer", func(t *testing.T) {
+		assert.Error(t, ApplyMergePatch(profile{}, []byte(`{}`)))
+	})
+}
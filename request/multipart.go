@@ -0,0 +1,245 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"sync"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// MultipartForm is a parsed multipart/form-data body.
+type MultipartForm struct {
+	Value map[string][]string
+	File  map[string][]*FileHeader
+}
+
+// FileHeader describes a file part of a multipart form. Its content is held
+// in memory or, once the form outgrows its memory budget, in a temporary
+// file that is removed when the server is done with the request.
+type FileHeader struct {
+	Filename string
+	Header   map[string]string
+	Size     int64
+
+	content []byte
+	tmpfile string
+	removed bool
+}
+
+// Open returns a reader of the file's content.
+func (fh *FileHeader) Open() (io.ReadCloser, error) {
+	switch {
+	case fh.removed:
+		return nil, errors.New("multipart file has been removed")
+	case fh.tmpfile != "":
+		return os.Open(fh.tmpfile)
+	default:
+		return io.NopCloser(bytes.NewReader(fh.content)), nil
+	}
+}
+
+// Remove discards the file's content, deleting its temporary file if it has
+// one, so a handler can free disk space before the request ends.
+func (fh *FileHeader) Remove() error {
+	fh.removed = true
+	fh.content = nil
+	if fh.tmpfile == "" {
+		return nil
+	}
+	err := os.Remove(fh.tmpfile)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return err
+}
+
+// uploads is shared by a request and its copies made by WithContext, so the
+// parsed forms and temporary files are seen by all of them.
+type uploads struct {
+	mu          sync.Mutex
+	form        *MultipartForm
+	files       []*FileHeader
+	postForm    map[string][]string
+	postFormErr error
+}
+
+// MultipartForm parses a multipart/form-data body. Up to maxMemory bytes of
+// it are kept in memory; file parts beyond that spill to temporary files,
+// which RemoveTempFiles deletes. The server calls it once the response has
+// been written. The form is parsed once; later calls return it again.
+func (r *Request) MultipartForm(maxMemory int64) (*MultipartForm, error) {
+	if r.uploads == nil {
+		r.uploads = &uploads{}
+	}
+	r.uploads.mu.Lock()
+	defer r.uploads.mu.Unlock()
+	if r.uploads.form != nil {
+		return r.uploads.form, nil
+	}
+
+	boundary, err := r.multipartBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &MultipartForm{Value: make(map[string][]string), File: make(map[string][]*FileHeader)}
+	reader := multipart.NewReader(r.Body, boundary)
+	remaining := maxMemory
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, httperrors.NewBadRequest("malformed multipart body")
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+
+		if part.FileName() == "" {
+			var value bytes.Buffer
+			n, err := io.CopyN(&value, part, remaining+1)
+			if err != nil && err != io.EOF {
+				return nil, httperrors.NewBadRequest("malformed multipart body")
+			}
+			if remaining -= n; remaining < 0 {
+				return nil, httperrors.NewPayloadTooLarge(maxMemory)
+			}
+			form.Value[name] = append(form.Value[name], value.String())
+			continue
+		}
+
+		fh := &FileHeader{Filename: part.FileName(), Header: make(map[string]string)}
+		for key, values := range part.Header {
+			fh.Header[key] = values[0]
+		}
+		// Registered before reading, so a partly written temporary file is
+		// still cleaned up if reading fails.
+		r.uploads.files = append(r.uploads.files, fh)
+		if err := fh.read(part, &remaining); err != nil {
+			return nil, err
+		}
+		form.File[name] = append(form.File[name], fh)
+	}
+	r.uploads.form = form
+	return form, nil
+}
+
+// read stores the content of part in fh, in memory while the budget lasts
+// and in a temporary file once it runs out.
+func (fh *FileHeader) read(part io.Reader, remaining *int64) error {
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, part, *remaining+1)
+	if err != nil && err != io.EOF {
+		return httperrors.NewBadRequest("malformed multipart body")
+	}
+	if n <= *remaining {
+		*remaining -= n
+		fh.content, fh.Size = buf.Bytes(), n
+		return nil
+	}
+
+	file, err := os.CreateTemp("", "rhttp-multipart-*")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fh.tmpfile = file.Name()
+	size, err := io.Copy(file, io.MultiReader(&buf, part))
+	if err != nil {
+		return err
+	}
+	fh.Size = size
+	return nil
+}
+
+// RemoveTempFiles deletes the temporary files of uploaded multipart files.
+// The server calls it once it has finished with the request; code parsing
+// requests without the server should call it itself.
+func (r *Request) RemoveTempFiles() error {
+	if r.uploads == nil {
+		return nil
+	}
+	r.uploads.mu.Lock()
+	defer r.uploads.mu.Unlock()
+	var errs []error
+	for _, fh := range r.uploads.files {
+		if err := fh.Remove(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.uploads.files = nil
+	return errors.Join(errs...)
+}
+
+// multipartBoundary returns the boundary of a multipart/form-data body.
+func (r *Request) multipartBoundary() (string, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Headers["Content-Type"])
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return "", httperrors.NewUnsupportedMediaType("expected multipart/form-data with a boundary")
+	}
+	return params["boundary"], nil
+}
+
+// MultipartReader iterates over the parts of a multipart/form-data body
+// without buffering them.
+type MultipartReader struct {
+	r *multipart.Reader
+}
+
+// Part is one part of a multipart body. Its content is read from the request
+// body as it is consumed, and is gone once the next part is requested.
+type Part struct {
+	// FormName is the field name and FileName the file name from the part's
+	// Content-Disposition; FileName is empty for plain fields.
+	FormName string
+	FileName string
+	Header   map[string]string
+
+	part *multipart.Part
+}
+
+// Read reads the part's content.
+func (p *Part) Read(b []byte) (int, error) {
+	return p.part.Read(b)
+}
+
+// Close discards the rest of the part.
+func (p *Part) Close() error {
+	return p.part.Close()
+}
+
+// MultipartReader returns a reader that streams the parts of a
+// multipart/form-data body one at a time, so large files can go straight to
+// storage. It is the streaming counterpart to MultipartForm; use one or the
+// other for a request.
+func (r *Request) MultipartReader() (*MultipartReader, error) {
+	boundary, err := r.multipartBoundary()
+	if err != nil {
+		return nil, err
+	}
+	return &MultipartReader{r: multipart.NewReader(r.Body, boundary)}, nil
+}
+
+// NextPart returns the next part, or io.EOF after the last one.
+func (mr *MultipartReader) NextPart() (*Part, error) {
+	part, err := mr.r.NextPart()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, httperrors.NewBadRequest("malformed multipart body")
+	}
+	header := make(map[string]string, len(part.Header))
+	for key, values := range part.Header {
+		header[key] = values[0]
+	}
+	return &Part{FormName: part.FormName(), FileName: part.FileName(), Header: header, part: part}, nil
+}
@@ -0,0 +1,150 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartRequest builds a request whose body is a multipart form with the
+// given fields and files.
+func multipartRequest(t *testing.T, fields map[string]string, files map[string]string) *Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, w.WriteField(name, value))
+	}
+	for name, content := range files {
+		part, err := w.CreateFormFile(name, name+".txt")
+		require.NoError(t, err)
+		_, err = io.WriteString(part, content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return &Request{
+		Method:  "POST",
+		Headers: map[string]string{"Content-Type": w.FormDataContentType()},
+		Body:    io.NopCloser(&body),
+	}
+}
+
+// readFile returns the content of fh.
+func readFile(t *testing.T, fh *FileHeader) string {
+	t.Helper()
+	f, err := fh.Open()
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMultipartForm(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	large := strings.Repeat("x", 2048)
+	req := multipartRequest(t, map[string]string{"title": "report"}, map[string]string{"small": "tiny", "large": large})
+
+	form, err := req.MultipartForm(1024)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"report"}, form.Value["title"])
+
+	small := form.File["small"][0]
+	assert.Equal(t, "small.txt", small.Filename)
+	assert.Equal(t, int64(4), small.Size)
+	assert.Equal(t, "tiny", readFile(t, small))
+
+	spilled := form.File["large"][0]
+	assert.Equal(t, int64(len(large)), spilled.Size)
+	assert.Equal(t, large, readFile(t, spilled))
+	entries, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the large file spills to disk")
+
+	again, err := req.MultipartForm(1024)
+	require.NoError(t, err)
+	assert.Same(t, form, again)
+
+	require.NoError(t, spilled.Remove())
+	entries, err = os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	_, err = spilled.Open()
+	assert.Error(t, err)
+	require.NoError(t, req.RemoveTempFiles())
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	req := multipartRequest(t, nil, map[string]string{"upload": strings.Repeat("y", 4096)})
+	// The handler sees a copy; cleanup through the original must still work.
+	copied := req.WithContext(req.Context())
+
+	_, err := copied.MultipartForm(100)
+	require.NoError(t, err)
+	entries, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, req.RemoveTempFiles())
+	entries, err = os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestMultipartFormErrors(t *testing.T) {
+	req := &Request{Headers: map[string]string{"Content-Type": "application/json"}, Body: io.NopCloser(strings.NewReader("{}"))}
+	_, err := req.MultipartForm(1024)
+	var httpErr *httperrors.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, 415, httpErr.StatusCode)
+
+	req = multipartRequest(t, map[string]string{"note": strings.Repeat("z", 200)}, nil)
+	_, err = req.MultipartForm(100)
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, 413, httpErr.StatusCode)
+}
+
+func TestMultipartReader(t *testing.T) {
+	req := multipartRequest(t, map[string]string{"title": "scan"}, map[string]string{"page": strings.Repeat("p", 5000)})
+
+	mr, err := req.MultipartReader()
+	require.NoError(t, err)
+
+	type streamed struct {
+		formName, fileName, content string
+	}
+	var parts []streamed
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		var out bytes.Buffer
+		_, err = io.Copy(&out, part)
+		require.NoError(t, err)
+		require.NoError(t, part.Close())
+		parts = append(parts, streamed{part.FormName, part.FileName, out.String()})
+	}
+
+	assert.Equal(t, []streamed{
+		{formName: "title", content: "scan"},
+		{formName: "page", fileName: "page.txt", content: strings.Repeat("p", 5000)},
+	}, parts)
+
+	_, err = (&Request{Headers: map[string]string{}}).MultipartReader()
+	var httpErr *httperrors.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, 415, httpErr.StatusCode)
+}
@@ -0,0 +1,31 @@
+package request
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// Param returns the path parameter key, or "" if the route did not capture it.
+func (r *Request) Param(key string) string {
+	return r.PathParams[key]
+}
+
+// ParamInt returns the path parameter key as an int. Params the route
+// constrains with ":name:int" were parsed during routing and cannot fail.
+// Otherwise the error is a 400 HTTPError, so handlers can return it as is.
+func (r *Request) ParamInt(key string) (int, error) {
+	if n, ok := r.PathParamInts[key]; ok {
+		return n, nil
+	}
+	v, ok := r.PathParams[key]
+	if !ok {
+		return 0, httperrors.NewBadRequest(fmt.Sprintf("missing path parameter '%s'", key))
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, httperrors.NewBadRequest(fmt.Sprintf("path parameter '%s' must be an integer", key))
+	}
+	return n, nil
+}
@@ -0,0 +1,37 @@
+package request
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamGetters(t *testing.T) {
+	req := &Request{PathParams: map[string]string{"id": "42", "slug": "hello"}}
+
+	assert.Equal(t, "hello", req.Param("slug"))
+	assert.Equal(t, "", req.Param("missing"))
+
+	id, err := req.ParamInt("id")
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+
+	testCases := []struct {
+		name string
+		key  string
+	}{
+		{name: "Missing param", key: "missing"},
+		{name: "Non-numeric param", key: "slug"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := req.ParamInt(tc.key)
+			var httpErr *httperrors.HTTPError
+			require.True(t, errors.As(err, &httpErr), "error should be an HTTPError")
+			assert.Equal(t, 400, httpErr.StatusCode)
+		})
+	}
+}
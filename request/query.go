@@ -0,0 +1,73 @@
+package request
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// queryValues returns the parsed query string, parsing it from the target
+// for requests not built by the parser.
+func (r *Request) queryValues() url.Values {
+	if r.Query != nil {
+		return r.Query
+	}
+	_, rawQuery, _ := strings.Cut(r.Target, "?")
+	values, _ := url.ParseQuery(rawQuery)
+	return values
+}
+
+// queryValue returns the first value for key in the query, as returned by
+// queryValues.
+func (r *Request) queryValue(key string) (string, bool) {
+	if v := r.queryValues()[key]; len(v) > 0 {
+		return v[0], true
+	}
+	return "", false
+}
+
+// QueryParam returns the first value of the query parameter key, or "".
+func (r *Request) QueryParam(key string) string {
+	v, _ := r.queryValue(key)
+	return v
+}
+
+// QueryValues returns every value of the query parameter key in the order
+// given, e.g. ["a", "b"] for ?tag=a&tag=b. It returns an empty slice if the
+// key is absent.
+func (r *Request) QueryValues(key string) []string {
+	if v := r.queryValues()[key]; len(v) > 0 {
+		return v
+	}
+	return []string{}
+}
+
+// QueryInt returns the query parameter key as an int, or def if it is missing or invalid.
+func (r *Request) QueryInt(key string, def int) int {
+	if v, ok := r.queryValue(key); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// QueryBool returns the query parameter key as a bool, or def if it is missing or invalid.
+func (r *Request) QueryBool(key string, def bool) bool {
+	if v, ok := r.queryValue(key); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// QueryFloat returns the query parameter key as a float64, or def if it is missing or invalid.
+func (r *Request) QueryFloat(key string, def float64) float64 {
+	if v, ok := r.queryValue(key); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
@@ -0,0 +1,88 @@
+package request
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedQueryGetters(t *testing.T) {
+	req := &Request{Target: "/search?page=3&limit=abc&debug=true&verbose=maybe&ratio=0.75&scale=x"}
+
+	t.Run("QueryInt", func(t *testing.T) {
+		assert.Equal(t, 3, req.QueryInt("page", 1))
+		assert.Equal(t, 10, req.QueryInt("limit", 10), "invalid value falls back to default")
+		assert.Equal(t, 7, req.QueryInt("missing", 7))
+	})
+
+	t.Run("QueryBool", func(t *testing.T) {
+		assert.True(t, req.QueryBool("debug", false))
+		assert.False(t, req.QueryBool("verbose", false), "invalid value falls back to default")
+		assert.True(t, req.QueryBool("missing", true))
+	})
+
+	t.Run("QueryFloat", func(t *testing.T) {
+		assert.Equal(t, 0.75, req.QueryFloat("ratio", 1))
+		assert.Equal(t, 1.5, req.QueryFloat("scale", 1.5), "invalid value falls back to default")
+		assert.Equal(t, 2.0, req.QueryFloat("missing", 2))
+	})
+}
+
+func TestQueryValues(t *testing.T) {
+	req := &Request{Target: "/items?tag=a&size=l&tag=b&empty="}
+
+	assert.Equal(t, []string{"a", "b"}, req.QueryValues("tag"))
+	assert.Equal(t, []string{""}, req.QueryValues("empty"))
+	missing := req.QueryValues("missing")
+	assert.NotNil(t, missing)
+	assert.Empty(t, missing)
+
+	assert.Equal(t, "a", req.QueryParam("tag"))
+	assert.Equal(t, "", req.QueryParam("missing"))
+}
+
+func TestParsedPathAndQuery(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		path   string
+		query  map[string][]string
+	}{
+		{name: "No query", target: "/search", path: "/search", query: map[string][]string{}},
+		{name: "Bare question mark", target: "/search?", path: "/search", query: map[string][]string{}},
+		{name: "Key without value", target: "/search?flag", path: "/search", query: map[string][]string{"flag": {""}}},
+		{name: "Repeated key", target: "/search?a=1&a=2", path: "/search", query: map[string][]string{"a": {"1", "2"}}},
+		{
+			name:   "Percent-encoded",
+			target: "/search?q=hello%20world&caf%C3%A9=1",
+			path:   "/search",
+			query:  map[string][]string{"q": {"hello world"}, "café": {"1"}},
+		},
+		{name: "Question mark in value", target: "/a/b?next=/c?d", path: "/a/b", query: map[string][]string{"next": {"/c?d"}}},
+		{name: "Encoded path segment", target: "/users/John%20Doe%2Fjr?x=1", path: "/users/John Doe/jr", query: map[string][]string{"x": {"1"}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			go func() {
+				defer clientConn.Close()
+				clientConn.Write([]byte("GET " + tc.target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+			}()
+
+			req, err := Parse(serverConn)
+			require.NoError(t, err)
+			assert.Equal(t, tc.target, req.Target)
+			assert.Equal(t, tc.path, req.Path)
+			rawPath, _, _ := strings.Cut(tc.target, "?")
+			assert.Equal(t, rawPath, req.RawPath)
+			assert.Equal(t, tc.query, req.Query)
+			for key, values := range tc.query {
+				assert.Equal(t, values[0], req.QueryParam(key))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package request
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// Range is a byte range of a resource, resolved against its size.
+type Range struct {
+	Start  int64
+	Length int64
+}
+
+// ContentRange returns the Content-Range header value for the range of a
+// resource of the given size.
+func (r Range) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// ParseRange parses a Range header such as "bytes=0-499", "bytes=500-" or
+// the suffix form "bytes=-500", possibly listing several comma-separated
+// ranges, and resolves it against a resource of the given size. Ends past
+// the resource are clamped to it. Ranges that start beyond the resource are
+// dropped; if none is left the error is a 416 Range Not Satisfiable, to be
+// answered with "Content-Range: bytes */size". A malformed header gives a
+// 400, though RFC 9110 lets callers ignore it and send the whole resource.
+// An empty header gives no ranges and no error.
+func ParseRange(header string, size int64) ([]Range, error) {
+	if header == "" {
+		return nil, nil
+	}
+	invalid := httperrors.NewBadRequest("invalid Range header")
+	specs, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, invalid
+	}
+
+	var ranges []Range
+	seen := false
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			// Empty list elements are allowed and skipped.
+			continue
+		}
+		seen = true
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, invalid
+		}
+
+		if first == "" {
+			// A suffix range: the final n bytes.
+			n, err := parseRangeInt(last)
+			if err != nil {
+				return nil, invalid
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			ranges = append(ranges, Range{Start: size - n, Length: n})
+			continue
+		}
+
+		start, err := parseRangeInt(first)
+		if err != nil {
+			return nil, invalid
+		}
+		end := size - 1
+		if last != "" {
+			if end, err = parseRangeInt(last); err != nil || end < start {
+				return nil, invalid
+			}
+			end = min(end, size-1)
+		}
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, Range{Start: start, Length: end - start + 1})
+	}
+	if !seen {
+		return nil, invalid
+	}
+	if len(ranges) == 0 {
+		return nil, httperrors.NewRangeNotSatisfiable(size)
+	}
+	return ranges, nil
+}
+
+// parseRangeInt parses a non-negative position of a byte range.
+func parseRangeInt(s string) (int64, error) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
@@ -0,0 +1,68 @@
+package request
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	const size = 1000
+
+	testCases := []struct {
+		name   string
+		header string
+		ranges []Range
+		status int
+	}{
+		{name: "No header"},
+		{name: "Start and end", header: "bytes=0-499", ranges: []Range{{Start: 0, Length: 500}}},
+		{name: "Open ended", header: "bytes=500-", ranges: []Range{{Start: 500, Length: 500}}},
+		{name: "Suffix", header: "bytes=-500", ranges: []Range{{Start: 500, Length: 500}}},
+		{name: "Suffix longer than resource", header: "bytes=-5000", ranges: []Range{{Start: 0, Length: 1000}}},
+		{name: "End clamped", header: "bytes=900-1999", ranges: []Range{{Start: 900, Length: 100}}},
+		{name: "Single byte", header: "bytes=999-999", ranges: []Range{{Start: 999, Length: 1}}},
+		{
+			name:   "Multiple",
+			header: "bytes=0-9, 20-29,-5",
+			ranges: []Range{{Start: 0, Length: 10}, {Start: 20, Length: 10}, {Start: 995, Length: 5}},
+		},
+		{name: "Unsatisfiable ranges dropped", header: "bytes=2000-2999, 0-0", ranges: []Range{{Start: 0, Length: 1}}},
+		{name: "Out of bounds", header: "bytes=1000-1999", status: 416},
+		{name: "Zero length suffix", header: "bytes=-0", status: 416},
+		{name: "Wrong unit", header: "items=0-1", status: 400},
+		{name: "Missing dash", header: "bytes=100", status: 400},
+		{name: "End before start", header: "bytes=500-100", status: 400},
+		{name: "Negative", header: "bytes=--5", status: 400},
+		{name: "Not a number", header: "bytes=a-b", status: 400},
+		{name: "Empty list", header: "bytes=,", status: 400},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges, err := ParseRange(tc.header, size)
+			if tc.status != 0 {
+				var httpErr *httperrors.HTTPError
+				require.True(t, errors.As(err, &httpErr), "got %v", err)
+				assert.Equal(t, tc.status, httpErr.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.ranges, ranges)
+		})
+	}
+}
+
+func TestParseRangeEmptyResource(t *testing.T) {
+	_, err := ParseRange("bytes=-10", 0)
+	var httpErr *httperrors.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, 416, httpErr.StatusCode)
+}
+
+func TestRangeContentRange(t *testing.T) {
+	assert.Equal(t, "bytes 500-999/1000", Range{Start: 500, Length: 500}.ContentRange(1000))
+}
@@ -3,96 +3,514 @@ package request
 import (
 	"bufio"
 	"context"
-	"errors"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net"
+	"net/textproto"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
 )
 
 // Request is the top level request struct
 type Request struct {
-	Method     string
-	Target     string
-	Version    string
-	Headers    map[string]string
+	Method string
+	// Target is the request target as sent, e.g. "/search?q=go". Path is
+	// its path with percent-escapes decoded, and RawPath the path as sent,
+	// which is what routes match segment by segment. Query is the query
+	// string decoded; a key without a value, as in "?flag", maps to [""].
+	Target  string
+	Path    string
+	RawPath string
+	Query   map[string][]string
+	Version string
+	// Headers maps each canonical header name to its value. A field sent on
+	// several lines has its values joined with ", ", or "; " for Cookie, as
+	// RFC 9110 allows for list-valued fields; RawHeaders keeps them apart.
+	Headers map[string]string
+	// RawHeaders holds the value of every header line as sent, in order,
+	// keyed by canonical name. Use it, or Values, for fields that cannot be
+	// joined, such as Set-Cookie. It is not updated when Headers is changed.
+	RawHeaders map[string][]string
 	Body       io.ReadCloser
 	PathParams map[string]string
-	ctx        context.Context
+	// PathParamInts holds the values of path params constrained to integers
+	// by the route, as parsed by the router, so ParamInt need not parse
+	// them again.
+	PathParamInts map[string]int
+	// RemoteAddr is the network address of the client, as set by the server.
+	RemoteAddr string
+	// TLS describes the connection's TLS session, or is nil for plaintext
+	// connections. It is set by the server.
+	TLS *tls.ConnectionState
+	// Trailers holds the trailer fields of a chunked body. It is filled in
+	// once the body has been read to the end.
+	Trailers map[string]string
+	// TrustedProxies lists the proxies whose X-Forwarded-For entries
+	// ClientIP believes, as configured on the server.
+	TrustedProxies []*net.IPNet
+	// ForwardedProtoHeader names the header in which trusted proxies report
+	// the client's scheme to Scheme. Empty means X-Forwarded-Proto.
+	ForwardedProtoHeader string
+	ctx                  context.Context
+	values               map[string]interface{}
+	start                time.Time
+	uploads              *uploads
 }
 
-// bodyReader implements io.ReadCloser for the request body.
+// bodyReader implements io.ReadCloser for the request body. Copies of a
+// request made by WithContext each get their own bodyReader sharing one
+// body.
 type bodyReader struct {
+	*body
+	// ctx is the context of the request holding this reader. Reads fail
+	// with its error once it is done.
+	ctx context.Context
+}
+
+// body is the state of a request body shared by every bodyReader over it.
+type body struct {
 	io.Reader
-	closer io.Closer
+	conn   net.Conn
+	closed bool
+	// interrupted is the error a read was cut short with when its context
+	// was done. The position in the stream is then unknown.
+	interrupted error
 }
 
+// Read reads from the body. If the request context is cancelled while Read
+// waits for the client, the connection's read deadline is used to interrupt
+// it and the context's error is returned.
+func (br *bodyReader) Read(p []byte) (int, error) {
+	if br.closed {
+		return 0, io.EOF
+	}
+	if br.interrupted != nil {
+		return 0, br.interrupted
+	}
+	if br.ctx == nil || br.ctx.Done() == nil || br.conn == nil {
+		return br.Reader.Read(p)
+	}
+	if err := br.ctx.Err(); err != nil {
+		return 0, err
+	}
+	stop := context.AfterFunc(br.ctx, func() {
+		br.conn.SetReadDeadline(time.Unix(1, 0))
+	})
+	n, err := br.Reader.Read(p)
+	if !stop() {
+		// The deadline may have cut the read short; lift it and report why.
+		br.conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			err = br.ctx.Err()
+			br.interrupted = err
+		}
+	}
+	return n, err
+}
+
+// Close marks the body consumed. It does not close the underlying connection;
+// instead it skips any unread bytes of the body so that the next request on
+// the connection starts at the right place.
 func (br *bodyReader) Close() error {
-	// Closing the body reader should not close the underlying connection
-	// until the response is written.
-	return nil
+	if br.closed {
+		return nil
+	}
+	br.closed = true
+	if br.interrupted != nil {
+		// A read was abandoned midway, so the rest of the body cannot be
+		// found reliably; leave the connection unusable.
+		return br.interrupted
+	}
+	_, err := io.Copy(io.Discard, br.Reader)
+	return err
+}
+
+// ErrBodyTruncated is returned when reading a request body whose connection
+// ends before the body does, short of its Content-Length or final chunk. It
+// wraps io.ErrUnexpectedEOF. The server answers it with 400, since the fault
+// is the client's.
+var ErrBodyTruncated = fmt.Errorf("request body ended early: %w", io.ErrUnexpectedEOF)
+
+// lengthReader reads exactly remaining bytes of a body framed by
+// Content-Length. Running out of input early is ErrBodyTruncated rather than
+// a clean end, so a truncated body is never mistaken for a complete one.
+type lengthReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (lr *lengthReader) Read(p []byte) (int, error) {
+	if lr.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > lr.remaining {
+		p = p[:lr.remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+	if err == io.EOF && lr.remaining > 0 {
+		err = ErrBodyTruncated
+	}
+	return n, err
+}
+
+// Set stores value under key in the request's value store, so middleware can
+// pass what it learned, such as an authenticated identity, to the handler.
+func (r *Request) Set(key string, value interface{}) {
+	if r.values == nil {
+		r.values = make(map[string]interface{})
+	}
+	r.values[key] = value
+}
+
+// Get returns the value stored under key by Set.
+func (r *Request) Get(key string) (interface{}, bool) {
+	value, ok := r.values[key]
+	return value, ok
+}
+
+// Context returns the request's context.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// WithContext returns a shallow copy of r with its context changed to ctx.
+// The copy shares r's body, but its reads stop with ctx's error once ctx is
+// done; reads through r are not affected.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if r.uploads == nil {
+		// Allocated before copying so r and the copy share it.
+		r.uploads = &uploads{}
+	}
+	r2 := *r
+	r2.ctx = ctx
+	if br, ok := r.Body.(*bodyReader); ok {
+		r2.Body = &bodyReader{body: br.body, ctx: ctx}
+	}
+	return &r2
+}
+
+// StartTime returns when the request began to arrive: the moment its first
+// byte was available, so time spent idle on a persistent connection is not
+// included. It is zero for requests not read by a Reader.
+func (r *Request) StartTime() time.Time {
+	return r.start
 }
 
 // Parse parses the complete request
 func Parse(conn net.Conn) (*Request, error) {
-	reader := bufio.NewReader(conn)
+	return NewReader(conn).Next()
+}
+
+// Reader parses successive requests from a single connection. It keeps one
+// buffered reader so bytes of a pipelined request are never lost.
+type Reader struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// MaxBodyBytes rejects requests declaring a larger body with a 413.
+	// Zero means no limit.
+	MaxBodyBytes int64
+
+	// HeaderFilter, if set, decides which headers are stored in
+	// Request.Headers; keys are passed in canonical form. Content-Length and
+	// Transfer-Encoding are always used to frame the body, whether or not
+	// they are stored.
+	HeaderFilter func(key string) bool
+
+	// MaxRequestLineBytes bounds the request line; a longer one is refused
+	// with 414 URI Too Long. Zero means DefaultMaxRequestLineBytes.
+	MaxRequestLineBytes int
+
+	// MaxHeaderBytes bounds the header block as a whole, line terminators
+	// included; a larger one is refused with 431. Zero means
+	// DefaultMaxHeaderBytes.
+	MaxHeaderBytes int
+
+	// AllowHTTP09 accepts HTTP/0.9 simple requests, a GET request line
+	// without a version, followed by no headers. Their Version is set to
+	// "HTTP/0.9" and their body is empty. When false, such a line is a 400.
+	AllowHTTP09 bool
+}
+
+// Limits applied when a Reader's are left at zero.
+const (
+	DefaultMaxRequestLineBytes = 8 << 10
+	DefaultMaxHeaderBytes      = 64 << 10
+)
+
+// NewReader creates a Reader for conn.
+func NewReader(conn net.Conn) *Reader {
+	return &Reader{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// Next parses the next request on the connection. The previous request's body
+// must be fully consumed first.
+func (rd *Reader) Next() (*Request, error) {
+	reader := rd.reader
+	// Wait for the request to begin before stamping its start time.
+	if _, err := reader.Peek(1); err != nil {
+		return nil, err
+	}
 	req := &Request{
 		Headers:    make(map[string]string),
+		RawHeaders: make(map[string][]string),
 		PathParams: make(map[string]string),
 		ctx:        context.Background(),
+		values:     make(map[string]interface{}),
+		start:      time.Now(),
+		uploads:    &uploads{},
+	}
+
+	lineLimit := rd.MaxRequestLineBytes
+	if lineLimit <= 0 {
+		lineLimit = DefaultMaxRequestLineBytes
+	}
+	headerLimit := rd.MaxHeaderBytes
+	if headerLimit <= 0 {
+		headerLimit = DefaultMaxHeaderBytes
 	}
 
-	if err := parseRequestLine(reader, req); err != nil {
+	simple, err := parseRequestLine(reader, req, lineLimit, rd.AllowHTTP09)
+	if err != nil {
 		return nil, err
 	}
-	if err := parseHeaders(reader, req); err != nil {
+	if simple {
+		req.Body = &bodyReader{body: &body{Reader: strings.NewReader(""), conn: rd.conn}}
+		return req, nil
+	}
+	framing, err := parseHeaders(reader, req.Headers, req.RawHeaders, rd.HeaderFilter, headerLimit)
+	if err != nil {
 		return nil, err
 	}
+	// The version is checked once the headers are read, so the error
+	// response is not sent while the client is still writing them.
+	if req.Version != "HTTP/1.1" && req.Version != "HTTP/1.0" {
+		if !strings.HasPrefix(req.Version, "HTTP/") {
+			return nil, httperrors.NewBadRequest("malformed HTTP version")
+		}
+		return nil, httperrors.NewHTTPVersionNotSupported(req.Version)
+	}
 
-	contentLengthStr := req.Headers["Content-Length"]
-	if contentLength, err := strconv.ParseInt(contentLengthStr, 10, 64); err == nil && contentLength > 0 {
-		req.Body = &bodyReader{
-			Reader: io.LimitReader(reader, contentLength),
-			closer: conn,
+	chunked, err := isChunked(framing.transferEncoding)
+	if err != nil {
+		return nil, err
+	}
+	if chunked {
+		// Transfer-Encoding overrides Content-Length (RFC 9112, section 6.3).
+		req.Body = &bodyReader{body: &body{
+			Reader: &chunkedReader{r: reader, req: req, limit: rd.MaxBodyBytes},
+			conn:   rd.conn,
+		}}
+	} else if contentLength, err := strconv.ParseInt(framing.contentLength, 10, 64); err == nil && contentLength > 0 {
+		if rd.MaxBodyBytes > 0 && contentLength > rd.MaxBodyBytes {
+			return nil, httperrors.NewPayloadTooLarge(rd.MaxBodyBytes)
 		}
+		req.Body = &bodyReader{body: &body{
+			Reader: &lengthReader{r: reader, remaining: contentLength},
+			conn:   rd.conn,
+		}}
 	} else {
 		// Body is empty or Content-Length is invalid/missing.
-		req.Body = &bodyReader{
+		req.Body = &bodyReader{body: &body{
 			Reader: strings.NewReader(""),
-			closer: conn,
-		}
+			conn:   rd.conn,
+		}}
 	}
 
 	return req, nil
 }
 
-func parseRequestLine(r *bufio.Reader, req *Request) error {
-	line, _, err := r.ReadLine()
+// Header returns the value of the header name, whatever its case, with the
+// values of repeated lines joined as in Headers. It returns "" if the header
+// is absent.
+func (r *Request) Header(name string) string {
+	return r.Headers[textproto.CanonicalMIMEHeaderKey(name)]
+}
+
+// Values returns the values of every line of the header name, whatever its
+// case, in the order they were sent. A comma-separated value on one line is
+// returned as one value. For requests not built by the parser, which have no
+// RawHeaders, it falls back to Headers.
+func (r *Request) Values(name string) []string {
+	key := textproto.CanonicalMIMEHeaderKey(name)
+	if values, ok := r.RawHeaders[key]; ok {
+		return values
+	}
+	if value, ok := r.Headers[key]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+// HasHeaderToken reports whether the comma-separated header name contains
+// token, compared case-insensitively (e.g. "Connection: keep-alive, Upgrade").
+func (r *Request) HasHeaderToken(name, token string) bool {
+	for _, v := range strings.Split(r.Headers[name], ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxLineLength bounds a chunk size line so a client cannot make the server
+// buffer an endless line.
+const maxLineLength = 8 << 10
+
+// readLine reads one line, returning tooLong as soon as the line grows beyond
+// max bytes rather than accumulating all of it.
+func readLine(r *bufio.Reader, max int, tooLong error) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line)+len(chunk) > max {
+			return nil, tooLong
+		}
+		line = append(line, chunk...)
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// parseRequestLine reads the request line into req. With allowSimple, a
+// two-token GET line is taken as an HTTP/0.9 simple request, which is
+// reported so that no headers are read for it.
+func parseRequestLine(r *bufio.Reader, req *Request, limit int, allowSimple bool) (simple bool, err error) {
+	line, err := readLine(r, limit, httperrors.NewURITooLong(limit))
 	if err != nil {
-		return err
+		return false, err
 	}
 	parts := strings.Split(string(line), " ")
+	if allowSimple && len(parts) == 2 && parts[0] == "GET" {
+		req.Method, req.Version = parts[0], "HTTP/0.9"
+		return true, req.setTarget(parts[1])
+	}
 	if len(parts) != 3 {
-		return errors.New("malformed request line")
+		return false, httperrors.NewBadRequest("malformed request line")
+	}
+	req.Method, req.Version = parts[0], parts[2]
+	return false, req.setTarget(parts[1])
+}
+
+// setTarget sets the request target along with the Path, RawPath and Query
+// parsed from it. A malformed escape in the path is a 400.
+func (r *Request) setTarget(target string) error {
+	r.Target = target
+	var rawQuery string
+	r.RawPath, rawQuery, _ = strings.Cut(target, "?")
+	path, err := url.PathUnescape(r.RawPath)
+	if err != nil {
+		return httperrors.NewBadRequest("malformed escape in request path")
 	}
-	req.Method, req.Target, req.Version = parts[0], parts[1], parts[2]
+	r.Path = path
+	// Malformed pairs are skipped; the rest of the query is still usable.
+	r.Query, _ = url.ParseQuery(rawQuery)
 	return nil
 }
 
-func parseHeaders(r *bufio.Reader, req *Request) error {
+// isChunked validates a Transfer-Encoding value and reports whether the body
+// is chunked. identity is a no-op, so a value made only of identity leaves
+// the body framed by Content-Length. chunked may appear once, as the final
+// coding; any other coding is answered with 501.
+func isChunked(transferEncoding string) (bool, error) {
+	chunked := false
+	for _, coding := range strings.Split(transferEncoding, ",") {
+		coding = strings.TrimSpace(coding)
+		switch {
+		case coding == "" || strings.EqualFold(coding, "identity"):
+		case strings.EqualFold(coding, "chunked"):
+			if chunked {
+				return false, httperrors.NewBadRequest("chunked transfer coding applied more than once")
+			}
+			chunked = true
+		case chunked:
+			return false, httperrors.NewBadRequest("chunked must be the final transfer coding")
+		default:
+			return false, httperrors.NewNotImplemented(fmt.Sprintf("transfer coding '%s' not supported", coding))
+		}
+	}
+	return chunked, nil
+}
+
+// framing holds the headers that determine where a request body ends.
+type framing struct {
+	contentLength    string
+	transferEncoding string
+}
+
+// parseHeaders stores the headers accepted by keep (all of them if keep is
+// nil) into headers, joining repeated fields, and each line's value into raw
+// unless it is nil. It returns the values needed to frame the body. The
+// block may take at most limit bytes, CRLFs included.
+func parseHeaders(r *bufio.Reader, headers map[string]string, raw map[string][]string, keep func(key string) bool, limit int) (framing, error) {
+	var f framing
+	var seenHost bool
+	tooLarge := httperrors.NewRequestHeaderFieldsTooLarge("header block too large")
 	for {
-		line, _, err := r.ReadLine()
+		line, err := readLine(r, limit, tooLarge)
 		if err != nil {
-			return err
+			return framing{}, err
 		}
+		limit -= len(line) + 2
 		if len(line) == 0 {
 			break
 		}
 		parts := strings.SplitN(string(line), ":", 2)
-		if len(parts) != 2 {
+		if len(parts) != 2 || strings.ContainsAny(parts[0], " \t") {
 			continue // Malformed header
 		}
-		req.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		key := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+		switch key {
+		case "Content-Length":
+			// Differing lengths leave the body's end ambiguous, which
+			// request smuggling relies on.
+			if f.contentLength != "" && f.contentLength != value {
+				return framing{}, httperrors.NewBadRequest("conflicting Content-Length headers")
+			}
+			f.contentLength = value
+		case "Transfer-Encoding":
+			f.transferEncoding = joinHeader(key, f.transferEncoding, value)
+		case "Host":
+			// RFC 9112 requires a 400 for more than one Host line; joining
+			// them would let proxies and the server disagree on the host.
+			if seenHost {
+				return framing{}, httperrors.NewBadRequest("multiple Host headers")
+			}
+			seenHost = true
+		}
+		if keep == nil || keep(key) {
+			headers[key] = joinHeader(key, headers[key], value)
+			if raw != nil {
+				raw[key] = append(raw[key], value)
+			}
+		}
+	}
+	return f, nil
+}
+
+// joinHeader appends value to the value prev already collected for the
+// header key, if any.
+func joinHeader(key, prev, value string) string {
+	switch {
+	case prev == "":
+		return value
+	case key == "Cookie":
+		return prev + "; " + value
+	default:
+		return prev + ", " + value
 	}
-	return nil
 }
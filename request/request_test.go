@@ -1,10 +1,14 @@
 package request
 
 import (
+	"context"
 	"io"
 	"net"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mohdrashid9678/rhttp/httperrors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -41,7 +45,7 @@ func TestParse(t *testing.T) {
 			rawRequest: "POST /api/users HTTP/1.1\r\n" +
 				"Host: api.example.com\r\n" +
 				"Content-Type: application/json\r\n" +
-				"Content-Length: 27\r\n\r\n" +
+				"Content-Length: 28\r\n\r\n" +
 				`{"username":"test","age":30}`,
 			expectErr: false,
 			expectedRequest: &Request{
@@ -51,7 +55,7 @@ func TestParse(t *testing.T) {
 				Headers: map[string]string{
 					"Host":           "api.example.com",
 					"Content-Type":   "application/json",
-					"Content-Length": "27",
+					"Content-Length": "28",
 				},
 			},
 			expectedBody: []byte(`{"username":"test","age":30}`),
@@ -165,3 +169,364 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRejectsOverlongLines(t *testing.T) {
+	testCases := []struct {
+		name       string
+		rawRequest string
+		status     int
+	}{
+		{
+			name:       "Header line longer than the read buffer",
+			rawRequest: "GET / HTTP/1.1\r\nX-Huge: " + strings.Repeat("a", 1<<20) + "\r\n\r\n",
+			status:     431,
+		},
+		{
+			name:       "Request line longer than the read buffer",
+			rawRequest: "GET /" + strings.Repeat("a", 1<<20) + " HTTP/1.1\r\n\r\n",
+			status:     414,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			written := make(chan int)
+			go func() {
+				// This write only completes if the parser swallows the whole line.
+				n, _ := clientConn.Write([]byte(tc.rawRequest))
+				written <- n
+			}()
+
+			_, err := Parse(serverConn)
+			serverConn.Close()
+
+			var httpErr *httperrors.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			assert.Equal(t, tc.status, httpErr.StatusCode)
+			assert.Less(t, <-written, len(tc.rawRequest), "the line should not be read to the end")
+		})
+	}
+}
+
+func TestCloseSkipsUnreadBody(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		defer clientConn.Close()
+		clientConn.Write([]byte("POST /first HTTP/1.1\r\nContent-Length: 11\r\n\r\nhello world" +
+			"GET /second HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	}()
+
+	reader := NewReader(serverConn)
+	first, err := reader.Next()
+	require.NoError(t, err)
+
+	// Read part of the body, then close it without finishing.
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(first.Body, buf)
+	require.NoError(t, err)
+	require.NoError(t, first.Body.Close())
+
+	n, err := first.Body.Read(buf)
+	assert.Zero(t, n)
+	assert.ErrorIs(t, err, io.EOF, "a closed body reads as consumed")
+
+	second, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "GET", second.Method)
+	assert.Equal(t, "/second", second.Target)
+	assert.Equal(t, "localhost", second.Headers["Host"])
+}
+
+func TestHeaderFilter(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		defer clientConn.Close()
+		clientConn.Write([]byte("POST /upload HTTP/1.1\r\nHost: localhost\r\nUser-Agent: test\r\nX-Trace: abc\r\nContent-Length: 5\r\n\r\nhello" +
+			"GET /next HTTP/1.1\r\nHost: localhost\r\nAccept: */*\r\n\r\n"))
+	}()
+
+	reader := NewReader(serverConn)
+	reader.HeaderFilter = func(key string) bool {
+		return key == "Content-Length" || key == "Host"
+	}
+	first, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Host": "localhost", "Content-Length": "5"}, first.Headers)
+	body, err := io.ReadAll(first.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	second, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "/next", second.Target)
+	assert.Equal(t, map[string]string{"Host": "localhost"}, second.Headers)
+}
+
+func TestHeaderFilterStillFramesBody(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		defer clientConn.Close()
+		clientConn.Write([]byte("POST /upload HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello"))
+	}()
+
+	reader := NewReader(serverConn)
+	reader.HeaderFilter = func(key string) bool { return false }
+	req, err := reader.Next()
+	require.NoError(t, err)
+	assert.Empty(t, req.Headers)
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestTruncatedBodyIsUnexpectedEOF(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		// Declare 100 bytes, send 11 and hang up.
+		clientConn.Write([]byte("POST /upload HTTP/1.1\r\nContent-Length: 100\r\n\r\nhello world"))
+		clientConn.Close()
+	}()
+
+	req, err := Parse(serverConn)
+	require.NoError(t, err)
+	body, err := io.ReadAll(req.Body)
+	assert.ErrorIs(t, err, ErrBodyTruncated)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "hello world", string(body))
+}
+
+func TestStartTime(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	const idle = 50 * time.Millisecond
+	var sent time.Time
+	go func() {
+		defer clientConn.Close()
+		// Stay idle first; the wait must not count towards the request.
+		time.Sleep(idle)
+		sent = time.Now()
+		clientConn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	}()
+
+	before := time.Now()
+	req, err := Parse(serverConn)
+	require.NoError(t, err)
+	start := req.StartTime()
+
+	assert.False(t, start.Before(before.Add(idle)), "start time includes the idle wait")
+	assert.False(t, start.Before(sent), "start time precedes the request")
+	assert.False(t, start.After(time.Now()))
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, time.Duration(0))
+	assert.Less(t, elapsed, time.Second)
+
+	assert.True(t, (&Request{}).StartTime().IsZero())
+}
+
+func TestBodyReadStopsOnContextCancel(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	go func() {
+		// Send part of the body, then stall.
+		clientConn.Write([]byte("POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\nabc"))
+	}()
+
+	req, err := Parse(serverConn)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(req.Context())
+	req = req.WithContext(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	body, err := io.ReadAll(req.Body)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "abc", string(body))
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = req.Body.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, req.Body.Close(), context.Canceled, "an abandoned body is not drained")
+}
+
+func TestBodyDrainedAfterContextEnds(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	go func() {
+		clientConn.Write([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello"))
+	}()
+
+	req, err := Parse(serverConn)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(req.Context())
+	derived := req.WithContext(ctx)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(derived.Body, buf)
+	require.NoError(t, err)
+	cancel()
+
+	// No read was interrupted, so the rest of the body can still be skipped.
+	_, err = derived.Body.Read(buf)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, req.Body.Close())
+}
+
+func TestRequestLineAndHeaderLimits(t *testing.T) {
+	testCases := []struct {
+		name       string
+		rawRequest string
+		status     int
+	}{
+		{
+			name:       "Request line over its limit",
+			rawRequest: "GET /" + strings.Repeat("a", 100) + " HTTP/1.1\r\n\r\n",
+			status:     414,
+		},
+		{
+			name: "Large header block under its limit",
+			rawRequest: "GET / HTTP/1.1\r\nX-One: " + strings.Repeat("a", 300) +
+				"\r\nX-Two: " + strings.Repeat("b", 300) + "\r\n\r\n",
+		},
+		{
+			name: "Header block over its limit",
+			rawRequest: "GET / HTTP/1.1\r\nX-One: " + strings.Repeat("a", 600) +
+				"\r\nX-Two: " + strings.Repeat("b", 600) + "\r\n\r\n",
+			status: 431,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			go func() {
+				clientConn.Write([]byte(tc.rawRequest))
+				clientConn.Close()
+			}()
+
+			reader := NewReader(serverConn)
+			reader.MaxRequestLineBytes = 64
+			reader.MaxHeaderBytes = 1024
+			req, err := reader.Next()
+			if tc.status == 0 {
+				require.NoError(t, err)
+				assert.Len(t, req.Headers["X-Two"], 300)
+				return
+			}
+			var httpErr *httperrors.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			assert.Equal(t, tc.status, httpErr.StatusCode)
+		})
+	}
+}
+
+func TestPipelinedRequestAfterBody(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		defer clientConn.Close()
+		// One write, so the first body and the next request share the
+		// reader's buffer.
+		clientConn.Write([]byte("POST /submit HTTP/1.1\r\nContent-Length: 11\r\n\r\nhello world" +
+			"GET /next?x=1 HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	}()
+
+	reader := NewReader(serverConn)
+	post, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "POST", post.Method)
+	body, err := io.ReadAll(post.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+	require.NoError(t, post.Body.Close())
+
+	get, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "GET", get.Method)
+	assert.Equal(t, "/next?x=1", get.Target)
+	assert.Equal(t, "localhost", get.Headers["Host"])
+	body, err = io.ReadAll(get.Body)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+}
+
+func TestRepeatedHeaders(t *testing.T) {
+	proxies, err := ParseNetworks([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		defer clientConn.Close()
+		clientConn.Write([]byte("GET / HTTP/1.1\r\n" +
+			"Host: localhost\r\n" +
+			"X-Forwarded-For: 203.0.113.7\r\n" +
+			"Accept-Encoding: gzip\r\n" +
+			"x-forwarded-for: 198.51.100.1, 10.0.0.5\r\n" +
+			"Accept-Encoding: deflate\r\n" +
+			"Cookie: a=1\r\n" +
+			"Cookie: b=2\r\n\r\n"))
+	}()
+
+	req, err := Parse(serverConn)
+	require.NoError(t, err)
+
+	assert.Equal(t, "203.0.113.7, 198.51.100.1, 10.0.0.5", req.Headers["X-Forwarded-For"])
+	assert.Equal(t, []string{"203.0.113.7", "198.51.100.1, 10.0.0.5"}, req.Values("x-forwarded-for"))
+	assert.Equal(t, "gzip, deflate", req.Header("accept-encoding"))
+	assert.True(t, req.HasHeaderToken("Accept-Encoding", "gzip"))
+	assert.Equal(t, "a=1; b=2", req.Header("Cookie"))
+	assert.Equal(t, []string{"a=1", "b=2"}, req.Values("Cookie"))
+	assert.Equal(t, []string{"localhost"}, req.Values("Host"))
+	assert.Nil(t, req.Values("Missing"))
+
+	// Every line counts towards the forwarding chain.
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.TrustedProxies = proxies
+	assert.Equal(t, "198.51.100.1", req.ClientIP())
+
+	handBuilt := &Request{Headers: map[string]string{"Accept": "*/*"}}
+	assert.Equal(t, []string{"*/*"}, handBuilt.Values("accept"))
+}
+
+func TestRepeatedFramingHeaders(t *testing.T) {
+	testCases := []struct {
+		name    string
+		headers string
+		status  int
+		body    string
+	}{
+		{name: "Identical Content-Length", headers: "Content-Length: 2\r\nContent-Length: 2\r\n", body: "hi"},
+		{name: "Conflicting Content-Length", headers: "Content-Length: 2\r\nContent-Length: 3\r\n", status: 400},
+		{name: "Chunked on two lines", headers: "Transfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n", status: 400},
+		{name: "Second Host", headers: "Content-Length: 2\r\nHost: evil.example\r\n", status: 400},
+		{name: "Repeated identical Host", headers: "Content-Length: 2\r\nHost: localhost\r\n", status: 400},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			go func() {
+				defer clientConn.Close()
+				clientConn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\n" + tc.headers + "\r\nhi"))
+			}()
+
+			req, err := Parse(serverConn)
+			if tc.status != 0 {
+				var httpErr *httperrors.HTTPError
+				require.ErrorAs(t, err, &httpErr)
+				assert.Equal(t, tc.status, httpErr.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(body))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package request
+
+import (
+	"net"
+	"strings"
+)
+
+// Scheme returns "https" if the request arrived over TLS, or if the peer is
+// one of TrustedProxies and reports https as the last entry of
+// ForwardedProtoHeader; otherwise "http". The header is ignored from other
+// peers, since any client can send it.
+func (r *Request) Scheme() string {
+	if r.TLS != nil {
+		return "https"
+	}
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !r.trusts(peer) {
+		return "http"
+	}
+	header := r.ForwardedProtoHeader
+	if header == "" {
+		header = "X-Forwarded-Proto"
+	}
+	// A proxy chain may list one scheme per hop. Only the last was added by
+	// the trusted peer; earlier ones may come from the client, as with
+	// X-Forwarded-For in ClientIP.
+	if strings.EqualFold(lastEntry(r.Headers[header]), "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client asked for: the Host header, or the last
+// X-Forwarded-Host entry, the one the peer added, when the peer is one of
+// TrustedProxies.
+func (r *Request) Host() string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if forwarded := r.Headers["X-Forwarded-Host"]; forwarded != "" && r.trusts(peer) {
+		return lastEntry(forwarded)
+	}
+	return r.Headers["Host"]
+}
+
+// AbsoluteURL returns path, which may carry a query, as an absolute URL on
+// the request's scheme and host, e.g. for a Location header.
+func (r *Request) AbsoluteURL(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return r.Scheme() + "://" + r.Host() + path
+}
+
+// lastEntry returns the last entry of a comma-separated header value.
+func lastEntry(value string) string {
+	if i := strings.LastIndex(value, ","); i >= 0 {
+		value = value[i+1:]
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,96 @@
+package request
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheme(t *testing.T) {
+	proxies, err := ParseNetworks([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		tls        bool
+		remoteAddr string
+		headers    map[string]string
+		header     string
+		expected   string
+	}{
+		{name: "Plaintext", remoteAddr: "203.0.113.5:1234", expected: "http"},
+		{name: "TLS", tls: true, remoteAddr: "203.0.113.5:1234", expected: "https"},
+		{name: "Trusted proxy header", remoteAddr: "10.0.0.2:1234", headers: map[string]string{"X-Forwarded-Proto": "https"}, expected: "https"},
+		{name: "Trusted proxy reports http", remoteAddr: "10.0.0.2:1234", headers: map[string]string{"X-Forwarded-Proto": "http"}, expected: "http"},
+		{name: "Untrusted header ignored", remoteAddr: "203.0.113.5:1234", headers: map[string]string{"X-Forwarded-Proto": "https"}, expected: "http"},
+		{name: "Proxy chain", remoteAddr: "10.0.0.2:1234", headers: map[string]string{"X-Forwarded-Proto": "http, HTTPS"}, expected: "https"},
+		{name: "Client-supplied entry ignored", remoteAddr: "10.0.0.2:1234", headers: map[string]string{"X-Forwarded-Proto": "https, http"}, expected: "http"},
+		{
+			name:       "Configured header",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-Proto": "http", "X-Scheme": "https"},
+			header:     "X-Scheme",
+			expected:   "https",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{
+				Headers:              tc.headers,
+				RemoteAddr:           tc.remoteAddr,
+				TrustedProxies:       proxies,
+				ForwardedProtoHeader: tc.header,
+			}
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			assert.Equal(t, tc.expected, req.Scheme())
+		})
+	}
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	proxies, err := ParseNetworks([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	req := &Request{
+		Headers:    map[string]string{"Host": "example.com"},
+		RemoteAddr: "203.0.113.5:1234",
+		TLS:        &tls.ConnectionState{},
+	}
+	assert.Equal(t, "https://example.com/next", req.AbsoluteURL("/next"))
+	assert.Equal(t, "https://example.com/items?page=2", req.AbsoluteURL("items?page=2"))
+
+	t.Run("Behind trusted proxy", func(t *testing.T) {
+		req := &Request{
+			Headers: map[string]string{
+				"Host":              "10.0.0.7:8080",
+				"X-Forwarded-Host":  "shop.example.com",
+				"X-Forwarded-Proto": "https",
+			},
+			RemoteAddr:     "10.0.0.2:1234",
+			TrustedProxies: proxies,
+		}
+		assert.Equal(t, "shop.example.com", req.Host())
+		assert.Equal(t, "https://shop.example.com/next", req.AbsoluteURL("/next"))
+	})
+
+	t.Run("Forwarded host chain", func(t *testing.T) {
+		req := &Request{
+			Headers:        map[string]string{"Host": "10.0.0.7:8080", "X-Forwarded-Host": "evil.example, shop.example.com"},
+			RemoteAddr:     "10.0.0.2:1234",
+			TrustedProxies: proxies,
+		}
+		assert.Equal(t, "shop.example.com", req.Host())
+	})
+
+	t.Run("Untrusted forwarded host ignored", func(t *testing.T) {
+		req := &Request{
+			Headers:    map[string]string{"Host": "example.com", "X-Forwarded-Host": "evil.example"},
+			RemoteAddr: "203.0.113.5:1234",
+		}
+		assert.Equal(t, "http://example.com/next", req.AbsoluteURL("/next"))
+	})
+}
@@ -0,0 +1,7 @@
+package request
+
+// IsWebSocketUpgrade reports whether the request asks to switch the
+// connection to the WebSocket protocol.
+func (r *Request) IsWebSocketUpgrade() bool {
+	return r.HasHeaderToken("Connection", "upgrade") && r.HasHeaderToken("Upgrade", "websocket")
+}
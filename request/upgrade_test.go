@@ -0,0 +1,29 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	testCases := []struct {
+		name     string
+		headers  map[string]string
+		expected bool
+	}{
+		{name: "Plain upgrade", headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"}, expected: true},
+		{name: "Case-insensitive", headers: map[string]string{"Connection": "upgrade", "Upgrade": "WebSocket"}, expected: true},
+		{name: "Multi-token Connection", headers: map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket"}, expected: true},
+		{name: "Missing Upgrade header", headers: map[string]string{"Connection": "Upgrade"}},
+		{name: "Missing Connection token", headers: map[string]string{"Connection": "keep-alive", "Upgrade": "websocket"}},
+		{name: "Other protocol", headers: map[string]string{"Connection": "Upgrade", "Upgrade": "h2c"}},
+		{name: "Token must match exactly", headers: map[string]string{"Connection": "Upgraded", "Upgrade": "websocket"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &Request{Headers: tc.headers}
+			assert.Equal(t, tc.expected, req.IsWebSocketUpgrade())
+		})
+	}
+}
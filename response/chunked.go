@@ -0,0 +1,46 @@
+package response
+
+import (
+	"fmt"
+	"io"
+)
+
+// chunkedWriter frames everything written to it using the chunked transfer
+// coding (RFC 9112, section 7.1). Each chunk is flushed as soon as it is
+// written, since a chunked body is usually being produced over time, unless
+// buffered is set and the owner flushes instead.
+type chunkedWriter struct {
+	w        io.Writer
+	buffered bool
+}
+
+type flusher interface {
+	Flush() error
+}
+
+func (cw *chunkedWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		// A zero-length chunk would terminate the body.
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(cw.w, "%x\r\n", len(p)); err != nil {
+		return 0, err
+	}
+	n, err := cw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if _, err = io.WriteString(cw.w, "\r\n"); err != nil {
+		return n, err
+	}
+	if f, ok := cw.w.(flusher); ok && !cw.buffered {
+		err = f.Flush()
+	}
+	return n, err
+}
+
+// Close writes the last-chunk and the empty trailer section.
+func (cw *chunkedWriter) Close() error {
+	_, err := io.WriteString(cw.w, "0\r\n\r\n")
+	return err
+}
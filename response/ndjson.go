@@ -0,0 +1,50 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// NDJSON is a helper to stream newline-delimited JSON. Each value received
+// from ch is encoded on its own line as it arrives, and the response ends when
+// ch is closed.
+func NDJSON(statusCode int, ch <-chan interface{}) *Response {
+	return NDJSONContext(context.Background(), statusCode, ch)
+}
+
+// NDJSONContext is like NDJSON but also stops streaming when ctx is done,
+// typically the request's context. The stream is then cut off rather than
+// ended cleanly, so the client can tell it is incomplete.
+func NDJSONContext(ctx context.Context, statusCode int, ch <-chan interface{}) *Response {
+	resp := New(statusCode, &ndjsonReader{ctx: ctx, ch: ch})
+	resp.Headers["Content-Type"] = "application/x-ndjson"
+	resp.Headers["Transfer-Encoding"] = "chunked"
+	return resp
+}
+
+// ndjsonReader encodes values from a channel into JSON lines on demand.
+type ndjsonReader struct {
+	ctx context.Context
+	ch  <-chan interface{}
+	buf bytes.Buffer
+}
+
+func (r *ndjsonReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case v, ok := <-r.ch:
+			if !ok {
+				return 0, io.EOF
+			}
+			// Encode terminates each value with a newline.
+			if err := json.NewEncoder(&r.buf).Encode(v); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return r.buf.Read(p)
+}
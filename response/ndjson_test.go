@@ -0,0 +1,49 @@
+package response
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSON(t *testing.T) {
+	ch := make(chan interface{}, 3)
+	ch <- map[string]int{"id": 1}
+	ch <- map[string]int{"id": 2}
+	ch <- map[string]int{"id": 3}
+	close(ch)
+
+	var buf bytes.Buffer
+	require.NoError(t, NDJSON(200, ch).Write(&buf))
+
+	resp, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", string(body))
+}
+
+func TestNDJSONContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan interface{})
+	go func() {
+		ch <- map[string]int{"id": 1}
+		cancel() // The channel is never closed; only cancellation can stop the stream.
+	}()
+
+	var buf bytes.Buffer
+	err := NDJSONContext(ctx, 200, ch).Write(&buf)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, buf.String(), "{\"id\":1}\n")
+	assert.NotContains(t, buf.String(), "0\r\n\r\n", "a cancelled stream is not terminated cleanly")
+}
@@ -0,0 +1,44 @@
+package response
+
+import (
+	"errors"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+)
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// Problem creates an RFC 7807 problem details response with Content-Type
+// application/problem+json. The type is "about:blank", for which the title
+// should be the status text; detail and instance are omitted when empty.
+func Problem(statusCode int, title, detail, instance string) (*Response, error) {
+	resp, err := JSON(statusCode, problem{
+		Type:     "about:blank",
+		Title:    title,
+		Status:   statusCode,
+		Detail:   detail,
+		Instance: instance,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.Headers["Content-Type"] = "application/problem+json"
+	return resp, nil
+}
+
+// ProblemError is like Error but renders problem details. It can be used as
+// a server's ErrorRenderer for APIs that follow RFC 7807.
+func ProblemError(err error) (*Response, error) {
+	var httpErr *httperrors.HTTPError
+	if errors.As(err, &httpErr) {
+		return Problem(httpErr.StatusCode, statusText[httpErr.StatusCode], httpErr.Message, "")
+	}
+	return Problem(500, statusText[500], "", "")
+}
@@ -0,0 +1,60 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// problemBody decodes a problem details response body.
+func problemBody(t *testing.T, resp *Response) map[string]interface{} {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &body))
+	return body
+}
+
+func TestProblem(t *testing.T) {
+	resp, err := Problem(403, "Forbidden", "Your account lacks credit.", "/account/12345/msgs/abc")
+	require.NoError(t, err)
+	assert.Equal(t, 403, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Headers["Content-Type"])
+	assert.Equal(t, map[string]interface{}{
+		"type":     "about:blank",
+		"title":    "Forbidden",
+		"status":   float64(403),
+		"detail":   "Your account lacks credit.",
+		"instance": "/account/12345/msgs/abc",
+	}, problemBody(t, resp))
+
+	resp, err = Problem(404, "Not Found", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Not Found",
+		"status": float64(404),
+	}, problemBody(t, resp))
+}
+
+func TestProblemError(t *testing.T) {
+	resp, err := ProblemError(httperrors.NewBadRequest("missing name"))
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+	body := problemBody(t, resp)
+	assert.Equal(t, "Bad Request", body["title"])
+	assert.Equal(t, "missing name", body["detail"])
+
+	resp, err = ProblemError(errors.New("database password is hunter2"))
+	require.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+	body = problemBody(t, resp)
+	assert.Equal(t, "Internal Server Error", body["title"])
+	assert.NotContains(t, body, "detail")
+}
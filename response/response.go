@@ -7,13 +7,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/mohdrashid9678/rhttp/httperrors"
 )
 
-// Response is the top level response type
+// Response is the top level response type. A handler can set a
+// "Connection: close" header to have the server close the connection after
+// sending the response.
 type Response struct {
 	StatusCode int
 	StatusText string
@@ -21,9 +25,33 @@ type Response struct {
 	Body       io.Reader
 }
 
+// ErrBodyRead is returned by Write when reading the body fails partway, or
+// the body turns out not to match its Content-Length. The response on the
+// wire is then incomplete and the connection must be closed.
+var ErrBodyRead = errors.New("failed to read response body")
+
+// ErrContentLengthMismatch reports a Content-Length header that does not match
+// the body. For bodies of known length it is returned before anything is
+// written.
+var ErrContentLengthMismatch = errors.New("content length mismatch")
+
+// ErrInvalidStatusCode is returned by Write for status codes outside 100-599.
+var ErrInvalidStatusCode = errors.New("invalid status code")
+
+// ErrBodyNotAllowed is returned by body writes to a response whose status,
+// 1xx, 204 or 304, does not allow a body.
+var ErrBodyNotAllowed = errors.New("response status does not allow a body")
+
 var statusText = map[int]string{
-	200: "OK", 201: "Created", 400: "Bad Request",
-	404: "Not Found", 500: "Internal Server Error",
+	100: "Continue", 103: "Early Hints",
+	200: "OK", 201: "Created", 204: "No Content",
+	301: "Moved Permanently", 302: "Found", 303: "See Other",
+	304: "Not Modified", 307: "Temporary Redirect", 308: "Permanent Redirect",
+	400: "Bad Request", 401: "Unauthorized", 403: "Forbidden", 404: "Not Found",
+	405: "Method Not Allowed", 412: "Precondition Failed", 413: "Content Too Large", 414: "URI Too Long",
+	415: "Unsupported Media Type", 416: "Range Not Satisfiable", 429: "Too Many Requests", 431: "Request Header Fields Too Large",
+	500: "Internal Server Error", 501: "Not Implemented", 503: "Service Unavailable",
+	505: "HTTP Version Not Supported",
 }
 
 // New creates a response with a streaming body.
@@ -36,6 +64,18 @@ func New(statusCode int, body io.Reader) *Response {
 	}
 }
 
+// Stream creates a response that copies contentLength bytes from r, framed
+// with Content-Length instead of chunked encoding. Write fails if r turns out
+// shorter or longer than declared.
+func Stream(statusCode int, r io.Reader, contentLength int64, contentType string) *Response {
+	resp := New(statusCode, r)
+	resp.Headers["Content-Length"] = strconv.FormatInt(contentLength, 10)
+	if contentType != "" {
+		resp.Headers["Content-Type"] = contentType
+	}
+	return resp
+}
+
 // Text is a helper to create a plain text response.
 func Text(statusCode int, text string) (*Response, error) {
 	resp := New(statusCode, strings.NewReader(text))
@@ -56,6 +96,38 @@ func JSON(statusCode int, v interface{}) (*Response, error) {
 	return resp, nil
 }
 
+// Redirect is a helper to create a redirect to location.
+func Redirect(statusCode int, location string) (*Response, error) {
+	loc, err := encodeLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	resp := New(statusCode, nil)
+	resp.Headers["Location"] = loc
+	return resp, nil
+}
+
+// Created is a helper to create a 201 response pointing at the new resource.
+func Created(location string, body io.Reader) (*Response, error) {
+	loc, err := encodeLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	resp := New(201, body)
+	resp.Headers["Location"] = loc
+	return resp, nil
+}
+
+// encodeLocation percent-encodes location so it is safe to put in a header.
+// Locations that are already encoded are left as they are.
+func encodeLocation(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid location '%s': %w", location, err)
+	}
+	return u.String(), nil
+}
+
 // Error is a helper to create a response from an error.
 func Error(err error) (*Response, error) {
 	var httpErr *httperrors.HTTPError
@@ -66,18 +138,206 @@ func Error(err error) (*Response, error) {
 	return Text(500, "Internal Server Error")
 }
 
-// Write sends the response to the client. It now supports streaming bodies.
+// Clone returns a copy of r whose status, headers and body are independent of
+// the original. A body can only be read once, so it is read into memory and
+// both responses get their own reader over it; cloning a streaming body
+// therefore buffers it whole. If reading fails, both bodies fail with the
+// same error after yielding what was read.
+func (r *Response) Clone() *Response {
+	clone := &Response{
+		StatusCode: r.StatusCode,
+		StatusText: r.StatusText,
+		Headers:    make(map[string]string, len(r.Headers)),
+	}
+	for k, v := range r.Headers {
+		clone.Headers[k] = v
+	}
+	if r.Body == nil {
+		return clone
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if closer, ok := r.Body.(io.Closer); ok {
+		closer.Close()
+	}
+	r.Body, clone.Body = bufferedBody(data, err), bufferedBody(data, err)
+	return clone
+}
+
+// bufferedBody returns a reader over data that ends with err, if any.
+func bufferedBody(data []byte, err error) io.Reader {
+	if err == nil {
+		return bytes.NewReader(data)
+	}
+	return io.MultiReader(bytes.NewReader(data), failingBody{err})
+}
+
+// failingBody is a body whose reads always fail with err.
+type failingBody struct{ err error }
+
+func (b failingBody) Read([]byte) (int, error) { return 0, b.err }
+
+// Write sends the response to the client. It now supports streaming bodies,
+// which are closed when Write returns, even on error, if they implement
+// io.Closer. A declared Content-Length is checked against the body.
 func (r *Response) Write(w io.Writer) error {
+	if closer, ok := r.Body.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if r.StatusCode < 100 || r.StatusCode > 599 {
+		return fmt.Errorf("%w: %d", ErrInvalidStatusCode, r.StatusCode)
+	}
+	if r.Headers == nil {
+		r.Headers = make(map[string]string)
+	}
+	if !bodyAllowed(r.StatusCode) {
+		// Any body is dropped. A 304's Content-Length describes the
+		// resource, not this response, so it is not checked.
+		r.Body = nil
+		delete(r.Headers, "Transfer-Encoding")
+	}
+	r.setFraming()
+	declared, hasLength, err := r.declaredLength()
+	if err != nil {
+		return err
+	}
+	if known, ok := bufferedLength(r.Body); ok && hasLength && known != declared && bodyAllowed(r.StatusCode) {
+		return fmt.Errorf("%w: declared %d bytes, body has %d", ErrContentLengthMismatch, declared, known)
+	}
+
 	writer := bufio.NewWriter(w)
 	fmt.Fprintf(writer, "HTTP/1.1 %d %s\r\n", r.StatusCode, r.StatusText)
-	for k, v := range r.Headers {
-		fmt.Fprintf(writer, "%s: %s\r\n", k, v)
-	}
-	writer.WriteString("\r\n")
+	writeHeaders(writer, r.Headers)
 	if r.Body != nil {
-		if _, err := io.Copy(writer, r.Body); err != nil {
-			return err
+		switch {
+		case r.Headers["Transfer-Encoding"] == "chunked":
+			chunked := &chunkedWriter{w: writer}
+			if _, err := io.Copy(chunked, bodySource{r.Body}); err != nil {
+				return err
+			}
+			if err := chunked.Close(); err != nil {
+				return err
+			}
+		case hasLength:
+			if err := copyDeclared(writer, r.Body, declared); err != nil {
+				return err
+			}
+		default:
+			if _, err := io.Copy(writer, bodySource{r.Body}); err != nil {
+				return err
+			}
 		}
 	}
 	return writer.Flush()
 }
+
+// writeHeaders writes the header block, ending with its blank line. Fields are
+// sorted by name so the output is the same on every write.
+func writeHeaders(w io.Writer, headers map[string]string) {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\r\n", k, headers[k])
+	}
+	io.WriteString(w, "\r\n")
+}
+
+// declaredLength parses the Content-Length header, if any.
+func (r *Response) declaredLength() (n int64, ok bool, err error) {
+	v, ok := r.Headers["Content-Length"]
+	if !ok {
+		return 0, false, nil
+	}
+	n, err = strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false, fmt.Errorf("%w: invalid value '%s'", ErrContentLengthMismatch, v)
+	}
+	return n, true, nil
+}
+
+// bufferedLength returns the length of bodies whose size is known up front,
+// such as strings.Reader, bytes.Reader and bytes.Buffer.
+func bufferedLength(body io.Reader) (int64, bool) {
+	if body == nil {
+		return 0, true
+	}
+	if l, ok := body.(interface{ Len() int }); ok {
+		return int64(l.Len()), true
+	}
+	return 0, false
+}
+
+// copyDeclared copies a body of declared length n, failing if the body turns
+// out to be shorter. A longer body is only detected when it is an io.Seeker,
+// such as a file: reading past n from a pipe or network stream could block
+// until its producer writes more. Otherwise the excess is not sent.
+func copyDeclared(w io.Writer, body io.Reader, n int64) error {
+	copied, err := io.CopyN(w, bodySource{body}, n)
+	if err == io.EOF {
+		return fmt.Errorf("%w: %w: body ended after %d of %d declared bytes", ErrBodyRead, ErrContentLengthMismatch, copied, n)
+	}
+	if err != nil {
+		return err
+	}
+	if seeker, ok := body.(io.Seeker); ok && hasMore(seeker) {
+		return fmt.Errorf("%w: %w: body is longer than the %d declared bytes", ErrBodyRead, ErrContentLengthMismatch, n)
+	}
+	return nil
+}
+
+// hasMore reports whether seeker has data past its current offset, leaving
+// the offset where it was. It reports false if seeking fails.
+func hasMore(seeker io.Seeker) bool {
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false
+	}
+	seeker.Seek(offset, io.SeekStart)
+	return end > offset
+}
+
+// bodySource tags errors from the body reader with ErrBodyRead so they can be
+// told apart from errors writing to the client.
+type bodySource struct {
+	r io.Reader
+}
+
+func (b bodySource) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", ErrBodyRead, err)
+	}
+	return n, err
+}
+
+// setFraming makes sure the client can tell where the body ends without the
+// connection being closed: a body of unknown length is sent chunked and a
+// missing body is declared empty.
+func (r *Response) setFraming() {
+	if _, ok := r.Headers["Content-Length"]; ok {
+		return
+	}
+	if _, ok := r.Headers["Transfer-Encoding"]; ok {
+		return
+	}
+	switch {
+	case !bodyAllowed(r.StatusCode):
+		// These responses never carry a body.
+	case r.Body != nil:
+		r.Headers["Transfer-Encoding"] = "chunked"
+	default:
+		r.Headers["Content-Length"] = "0"
+	}
+}
+
+// bodyAllowed reports whether a response with statusCode may carry a body.
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != 204 && statusCode != 304
+}
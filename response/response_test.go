@@ -0,0 +1,279 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteValidatesStatusCode(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		expectErr  bool
+	}{
+		{name: "Informational lower bound", statusCode: 100},
+		{name: "OK", statusCode: 200},
+		{name: "Upper bound", statusCode: 599},
+		{name: "Typo with extra digit", statusCode: 2000, expectErr: true},
+		{name: "Below range", statusCode: 99, expectErr: true},
+		{name: "Zero", statusCode: 0, expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := New(tc.statusCode, nil).Write(&buf)
+			if tc.expectErr {
+				require.ErrorIs(t, err, ErrInvalidStatusCode)
+				assert.Zero(t, buf.Len(), "nothing should be written for an invalid status")
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, buf.String(), "HTTP/1.1 ")
+		})
+	}
+}
+
+// failingReader returns data and then fails with err.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, f.err
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+func TestWriteDropsBodyForBodilessStatus(t *testing.T) {
+	for _, status := range []int{204, 304} {
+		resp := New(status, strings.NewReader("ignored"))
+		if status == 304 {
+			// The length of the unmodified resource, not of this response.
+			resp.Headers["Content-Length"] = "1234"
+		}
+		var buf bytes.Buffer
+		require.NoError(t, resp.Write(&buf))
+		assert.NotContains(t, buf.String(), "ignored")
+		assert.NotContains(t, buf.String(), "Transfer-Encoding")
+		assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n"), buf.String())
+	}
+}
+
+// closeTracker is a body that records whether it was closed.
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWriteClosesBodyOnError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status int
+		length string
+	}{
+		{name: "Invalid status", status: 2000},
+		{name: "Content-Length mismatch", status: 200, length: "99"},
+		{name: "Invalid Content-Length", status: 200, length: "ten"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := &closeTracker{Reader: strings.NewReader("hello")}
+			resp := New(tc.status, body)
+			if tc.length != "" {
+				resp.Headers["Content-Length"] = tc.length
+			}
+			assert.Error(t, resp.Write(io.Discard))
+			assert.True(t, body.closed, "the body must be closed when Write fails")
+		})
+	}
+}
+
+func TestWriteReportsBodyReadError(t *testing.T) {
+	diskErr := errors.New("disk on fire")
+	resp := New(200, &failingReader{data: []byte("partial"), err: diskErr})
+
+	var buf bytes.Buffer
+	err := resp.Write(&buf)
+	assert.ErrorIs(t, err, ErrBodyRead)
+	assert.ErrorIs(t, err, diskErr)
+	assert.NotContains(t, buf.String(), "0\r\n\r\n", "a failed body must not be terminated as if complete")
+}
+
+func TestWriteDetectsContentLengthMismatch(t *testing.T) {
+	testCases := []struct {
+		name      string
+		length    string
+		body      io.Reader
+		preWrite  bool // The mismatch is caught before anything is written.
+		expectErr bool
+	}{
+		{name: "Buffered body matches", length: "5", body: strings.NewReader("hello")},
+		{name: "Buffered body shorter", length: "10", body: strings.NewReader("hello"), preWrite: true, expectErr: true},
+		{name: "Buffered body longer", length: "3", body: bytes.NewBufferString("hello"), preWrite: true, expectErr: true},
+		{name: "Missing body", length: "3", body: nil, preWrite: true, expectErr: true},
+		{name: "Invalid header", length: "ten", body: strings.NewReader("hello"), preWrite: true, expectErr: true},
+		{name: "Streamed body matches", length: "5", body: io.MultiReader(strings.NewReader("hello"))},
+		{name: "Streamed body shorter", length: "10", body: io.MultiReader(strings.NewReader("hello")), expectErr: true},
+		{name: "Seekable body longer", length: "3", body: io.NewSectionReader(strings.NewReader("hello"), 0, 5), expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := New(200, tc.body)
+			resp.Headers["Content-Length"] = tc.length
+
+			var buf bytes.Buffer
+			err := resp.Write(&buf)
+			if !tc.expectErr {
+				require.NoError(t, err)
+				assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\nhello"))
+				return
+			}
+			require.ErrorIs(t, err, ErrContentLengthMismatch)
+			if tc.preWrite {
+				assert.Zero(t, buf.Len())
+			} else {
+				assert.ErrorIs(t, err, ErrBodyRead, "a streamed mismatch must abort the connection")
+			}
+		})
+	}
+}
+
+func TestWriteDoesNotReadPastDeclaredLength(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte("hello"))
+	// The producer keeps the pipe open, as a slow upstream would.
+
+	resp := New(200, pr)
+	resp.Headers["Content-Length"] = "5"
+	done := make(chan error, 1)
+	var buf bytes.Buffer
+	go func() { done <- resp.Write(&buf) }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\nhello"))
+	case <-time.After(time.Second):
+		t.Fatal("Write blocked reading past the declared length")
+	}
+}
+
+func TestLocationIsPercentEncoded(t *testing.T) {
+	testCases := []struct {
+		name     string
+		location string
+		expected string
+	}{
+		{name: "Spaces and unicode", location: "/files/my report/ünïcode.txt", expected: "/files/my%20report/%C3%BCn%C3%AFcode.txt"},
+		{name: "Query is kept", location: "/search?q=a+b", expected: "/search?q=a+b"},
+		{name: "Already encoded", location: "/a%20b", expected: "/a%20b"},
+		{name: "Absolute URL", location: "https://example.com/a b", expected: "https://example.com/a%20b"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := Redirect(302, tc.location)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resp.Headers["Location"])
+
+			resp, err = Created(tc.location, nil)
+			require.NoError(t, err)
+			assert.Equal(t, 201, resp.StatusCode)
+			assert.Equal(t, tc.expected, resp.Headers["Location"])
+		})
+	}
+
+	t.Run("Emitted header", func(t *testing.T) {
+		resp, err := Redirect(301, "/a b/ü")
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, resp.Write(&buf))
+		assert.Contains(t, buf.String(), "Location: /a%20b/%C3%BC\r\n")
+	})
+
+	t.Run("Invalid location", func(t *testing.T) {
+		_, err := Redirect(302, "http://[::1")
+		assert.Error(t, err)
+	})
+}
+
+func TestStream(t *testing.T) {
+	payload := strings.Repeat("0123456789", 1000)
+	// io.MultiReader hides the length, as a file or network stream would.
+	resp := Stream(200, io.MultiReader(strings.NewReader(payload)), int64(len(payload)), "application/octet-stream")
+
+	var buf bytes.Buffer
+	require.NoError(t, resp.Write(&buf))
+
+	head, body, found := strings.Cut(buf.String(), "\r\n\r\n")
+	require.True(t, found)
+	lines := strings.Split(head, "\r\n")
+	assert.Equal(t, "HTTP/1.1 200 OK", lines[0])
+	assert.ElementsMatch(t, []string{"Content-Length: 10000", "Content-Type: application/octet-stream"}, lines[1:])
+	assert.Equal(t, payload, body)
+}
+
+func TestWriteWithNilHeaders(t *testing.T) {
+	resp := &Response{StatusCode: 200, Body: strings.NewReader("bare")}
+	var buf bytes.Buffer
+	require.NoError(t, resp.Write(&buf))
+	assert.True(t, strings.HasSuffix(buf.String(), "Transfer-Encoding: chunked\r\n\r\n4\r\nbare\r\n0\r\n\r\n"), buf.String())
+}
+
+func TestClone(t *testing.T) {
+	t.Run("Independent copies", func(t *testing.T) {
+		orig, err := Text(200, "hello")
+		require.NoError(t, err)
+		clone := orig.Clone()
+
+		clone.Headers["X-Cache"] = "HIT"
+		clone.Headers["Content-Type"] = "text/html"
+		clone.StatusCode = 203
+		assert.NotContains(t, orig.Headers, "X-Cache")
+		assert.Equal(t, "text/plain; charset=utf-8", orig.Headers["Content-Type"])
+		assert.Equal(t, 200, orig.StatusCode)
+
+		for _, resp := range []*Response{orig, clone} {
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(body))
+		}
+	})
+
+	t.Run("Streaming body is buffered", func(t *testing.T) {
+		orig := New(200, io.MultiReader(strings.NewReader("chunk1"), strings.NewReader("chunk2")))
+		clone := orig.Clone()
+
+		var buf bytes.Buffer
+		require.NoError(t, clone.Write(&buf))
+		assert.True(t, strings.HasSuffix(buf.String(), "chunk1chunk2\r\n0\r\n\r\n"))
+		body, err := io.ReadAll(orig.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "chunk1chunk2", string(body))
+	})
+
+	t.Run("Read error is kept", func(t *testing.T) {
+		orig := New(200, &failingReader{data: []byte("par"), err: errors.New("disk error")})
+		clone := orig.Clone()
+		_, err := io.ReadAll(clone.Body)
+		assert.Error(t, err)
+		_, err = io.ReadAll(orig.Body)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,226 @@
+package response
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ResponseWriter lets a handler stream its response incrementally instead of
+// returning a complete *Response.
+type ResponseWriter interface {
+	// Header returns the headers that will be sent with the status line.
+	// Changes after the first write have no effect.
+	Header() map[string]string
+	// WriteHeader sends the status line and headers.
+	WriteHeader(statusCode int)
+	// Write sends part of the body, sending a 200 status line first if needed.
+	Write(p []byte) (int, error)
+	// WriteString is like Write but takes a string.
+	WriteString(s string) (int, error)
+	// WriteJSON marshals v and writes it as part of the body.
+	WriteJSON(v interface{}) error
+	// WriteInformational sends a 1xx response, such as 103 Early Hints,
+	// ahead of the final one. It fails once the final status has been sent.
+	WriteInformational(statusCode int, headers map[string]string) error
+	// Flush sends any buffered body data to the client immediately.
+	Flush() error
+}
+
+// Writer is the connection-backed ResponseWriter used by the server. Bodies
+// without a Content-Length header are sent with chunked framing.
+type Writer struct {
+	// FlushInterval, if positive, buffers body writes and flushes them at
+	// most this long after the first unflushed one, so many small writes
+	// cost fewer syscalls. Zero flushes every write. It must be set before
+	// the first write.
+	FlushInterval time.Duration
+
+	mu          sync.Mutex
+	w           *bufio.Writer
+	headers     map[string]string
+	wroteHeader bool
+	body        io.Writer
+	chunked     *chunkedWriter
+	flushTimer  *time.Timer
+	// bodyErr, if set, fails every body write: the status sent does not
+	// allow a body.
+	bodyErr error
+}
+
+// NewWriter creates a Writer that sends its response to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:       bufio.NewWriter(w),
+		headers: make(map[string]string),
+	}
+}
+
+// Header returns the response headers.
+func (w *Writer) Header() map[string]string {
+	return w.headers
+}
+
+// WriteHeader sends the status line and headers. Only the first call has an
+// effect. A status outside 100-599 is not sent: the client gets an empty 500
+// instead, and body writes fail with ErrInvalidStatusCode. Statuses that
+// carry no body, 1xx, 204 and 304, are sent without framing, and body writes
+// fail with ErrBodyNotAllowed.
+func (w *Writer) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	w.body = w.w
+	_, hasLength := w.headers["Content-Length"]
+	switch {
+	case statusCode < 100 || statusCode > 599:
+		w.bodyErr = fmt.Errorf("%w: %d", ErrInvalidStatusCode, statusCode)
+		statusCode = 500
+		delete(w.headers, "Transfer-Encoding")
+		w.headers["Content-Length"] = "0"
+	case !bodyAllowed(statusCode):
+		w.bodyErr = fmt.Errorf("%w: status %d", ErrBodyNotAllowed, statusCode)
+		delete(w.headers, "Transfer-Encoding")
+	case !hasLength:
+		w.headers["Transfer-Encoding"] = "chunked"
+		w.chunked = &chunkedWriter{w: w.w, buffered: w.FlushInterval > 0}
+		w.body = w.chunked
+	}
+	fmt.Fprintf(w.w, "HTTP/1.1 %d %s\r\n", statusCode, statusText[statusCode])
+	writeHeaders(w.w, w.headers)
+	w.w.Flush()
+}
+
+// WriteInformational sends a 1xx status line and headers without starting the
+// final response. 101 is excluded since it ends HTTP on the connection.
+func (w *Writer) WriteInformational(statusCode int, headers map[string]string) error {
+	if statusCode < 100 || statusCode > 199 || statusCode == 101 {
+		return fmt.Errorf("%w: %d is not an informational status", ErrInvalidStatusCode, statusCode)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.wroteHeader {
+		return errors.New("informational response after the final status")
+	}
+	fmt.Fprintf(w.w, "HTTP/1.1 %d %s\r\n", statusCode, statusText[statusCode])
+	writeHeaders(w.w, headers)
+	return w.w.Flush()
+}
+
+// Write sends p as part of the body and flushes it to the client, or
+// schedules a flush if FlushInterval is set.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.WriteHeader(200)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.bodyErr != nil {
+		return 0, w.bodyErr
+	}
+	n, err := w.body.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if w.FlushInterval <= 0 {
+		return n, w.w.Flush()
+	}
+	if w.flushTimer == nil {
+		w.flushTimer = time.AfterFunc(w.FlushInterval, w.timedFlush)
+	}
+	return n, nil
+}
+
+// Flush sends buffered body data to the client now.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stopFlushTimer()
+	return w.w.Flush()
+}
+
+// timedFlush runs when FlushInterval has passed since the first unflushed
+// write. A failure here shows up again on the next write.
+func (w *Writer) timedFlush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.flushTimer = nil
+	w.w.Flush()
+}
+
+func (w *Writer) stopFlushTimer() {
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+		w.flushTimer = nil
+	}
+}
+
+// WriteString sends s as part of the body.
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteJSON marshals v and sends it as part of the body. The JSON content type
+// is set only if the headers have not been sent yet.
+func (w *Writer) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if !w.Started() {
+		if _, ok := w.headers["Content-Type"]; !ok {
+			w.headers["Content-Type"] = "application/json; charset=utf-8"
+		}
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Started reports whether the status line has been sent.
+func (w *Writer) Started() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.wroteHeader
+}
+
+// Close finishes a started response, terminating a chunked body.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stopFlushTimer()
+	if !w.wroteHeader {
+		return nil
+	}
+	if w.chunked != nil {
+		if err := w.chunked.Close(); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+type writerContextKey struct{}
+
+// NewContext returns a copy of ctx that carries w.
+func NewContext(ctx context.Context, w ResponseWriter) context.Context {
+	return context.WithValue(ctx, writerContextKey{}, w)
+}
+
+// WriterFromContext returns the ResponseWriter stored in ctx by the server.
+func WriterFromContext(ctx context.Context) (ResponseWriter, bool) {
+	w, ok := ctx.Value(writerContextKey{}).(ResponseWriter)
+	return w, ok
+}
@@ -0,0 +1,164 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterWriteString(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	n, err := w.WriteString("hello")
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Transfer-Encoding: chunked\r\n\r\n"+
+		"5\r\nhello\r\n"+
+		"0\r\n\r\n", buf.String())
+}
+
+func TestWriterWriteHeaderStatus(t *testing.T) {
+	t.Run("Invalid status", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.WriteHeader(2000)
+		_, err := w.WriteString("hello")
+		assert.ErrorIs(t, err, ErrInvalidStatusCode)
+		require.NoError(t, w.Close())
+		assert.Equal(t, "HTTP/1.1 500 Internal Server Error\r\nContent-Length: 0\r\n\r\n", buf.String())
+	})
+
+	for _, status := range []int{204, 304} {
+		t.Run(statusText[status], func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.WriteHeader(status)
+			_, err := w.WriteString("hello")
+			assert.ErrorIs(t, err, ErrBodyNotAllowed)
+			require.NoError(t, w.Close())
+			assert.Equal(t, fmt.Sprintf("HTTP/1.1 %d %s\r\n\r\n", status, statusText[status]), buf.String())
+		})
+	}
+}
+
+func TestWriterWriteJSON(t *testing.T) {
+	t.Run("Before headers are sent", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Header()["Content-Length"] = "11"
+
+		require.NoError(t, w.WriteJSON(map[string]int{"a": 1}))
+		require.NoError(t, w.Close())
+
+		out := buf.String()
+		assert.Contains(t, out, "Content-Type: application/json; charset=utf-8\r\n")
+		assert.Contains(t, out, "\r\n\r\n{\"a\":1}")
+		assert.NotContains(t, out, "Transfer-Encoding")
+	})
+
+	t.Run("After headers are sent", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+
+		_, err := w.WriteString("[")
+		require.NoError(t, err)
+		require.NoError(t, w.WriteJSON(map[string]int{"a": 1}))
+		require.NoError(t, w.Close())
+
+		out := buf.String()
+		assert.NotContains(t, out, "Content-Type", "headers were already flushed")
+		assert.Contains(t, out, "7\r\n{\"a\":1}\r\n")
+	})
+}
+
+func TestWriterFromContext(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{})
+	ctx := NewContext(context.Background(), w)
+
+	got, ok := WriterFromContext(ctx)
+	require.True(t, ok)
+	assert.Same(t, w, got)
+
+	_, ok = WriterFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestWriterWriteInformational(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	require.NoError(t, w.WriteInformational(103, map[string]string{"Link": "</style.css>; rel=preload; as=style"}))
+	w.Header()["Content-Length"] = "5"
+	_, err := w.WriteString("hello")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\n"+
+		"Link: </style.css>; rel=preload; as=style\r\n\r\n"+
+		"HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 5\r\n\r\n"+
+		"hello", buf.String())
+
+	assert.Error(t, w.WriteInformational(103, nil), "the final status has already been sent")
+	assert.ErrorIs(t, NewWriter(&buf).WriteInformational(200, nil), ErrInvalidStatusCode)
+}
+
+// syncBuffer is a bytes.Buffer that can be written by a flush timer while the
+// test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWriterFlushInterval(t *testing.T) {
+	t.Run("Auto-flush", func(t *testing.T) {
+		var out syncBuffer
+		w := NewWriter(&out)
+		w.FlushInterval = 20 * time.Millisecond
+
+		w.WriteString("a")
+		w.WriteString("b")
+		assert.NotContains(t, out.String(), "1\r\na\r\n", "writes are buffered until the interval passes")
+
+		assert.Eventually(t, func() bool {
+			return strings.HasSuffix(out.String(), "1\r\na\r\n1\r\nb\r\n")
+		}, time.Second, 5*time.Millisecond)
+		require.NoError(t, w.Close())
+		assert.True(t, strings.HasSuffix(out.String(), "0\r\n\r\n"))
+	})
+
+	t.Run("Explicit Flush", func(t *testing.T) {
+		var out syncBuffer
+		w := NewWriter(&out)
+		w.FlushInterval = time.Hour
+
+		w.WriteString("hello")
+		assert.NotContains(t, out.String(), "hello")
+		require.NoError(t, w.Flush())
+		assert.True(t, strings.HasSuffix(out.String(), "5\r\nhello\r\n"))
+		require.NoError(t, w.Close())
+	})
+}
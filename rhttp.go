@@ -1,11 +1,29 @@
 package rhttp
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
 	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/middleware"
 	"github.com/mohdrashid9678/rhttp/request"
 	"github.com/mohdrashid9678/rhttp/response"
 	"github.com/mohdrashid9678/rhttp/router"
@@ -13,8 +31,166 @@ import (
 
 // Server is the core for serving http requests.
 type Server struct {
-	addr   string
-	router *router.Router
+	addr        string
+	router      *router.Router
+	middlewares []middleware.Middleware
+	// middlewareNames holds the name of each of middlewares.
+	middlewareNames []string
+	onError         []func(req *request.Request, err error)
+	fallback        router.Handler
+	preRoute        []func(req *request.Request) *response.Response
+
+	// builtin holds the middleware configured through Server fields, such
+	// as CompressionLevel, built once by builtinMiddleware.
+	builtin     []middleware.Middleware
+	builtinErr  error
+	builtinOnce sync.Once
+
+	// parsedErrorPages holds the templates of ErrorPages, parsed once by
+	// errorPages.
+	parsedErrorPages map[int]*template.Template
+	errorPagesErr    error
+	errorPagesOnce   sync.Once
+
+	// KeepAlive enables persistent connections, so a client may send several
+	// requests over one connection until either side asks to close it.
+	KeepAlive bool
+
+	// MaxBodyBytes rejects requests that declare a larger body with 413
+	// Content Too Large. Zero means no limit.
+	MaxBodyBytes int64
+
+	// ErrorRenderer, if set, turns errors into responses instead of
+	// response.Error. It is used for handler errors and for errors the
+	// server produces itself, such as parse failures and exceeded limits.
+	ErrorRenderer func(err error) (*response.Response, error)
+
+	// ErrorPages, if set, holds HTML error pages named after their status,
+	// such as 404.html and 500.html. They are parsed as html/template
+	// templates when the server starts, which fails if one does not parse,
+	// and rendered with an ErrorPage. Statuses without a page fall back to
+	// ErrorRenderer or the plain response.
+	ErrorPages fs.FS
+
+	// MaxRequestsPerConn closes a persistent connection after it has served
+	// this many requests. Zero means unlimited.
+	MaxRequestsPerConn int
+
+	// KeepAliveTimeout caps the total lifetime of a persistent connection.
+	// The first response sent after it has passed carries Connection: close
+	// and the connection is closed. Zero means unlimited.
+	KeepAliveTimeout time.Duration
+
+	// AllowMethodOverride lets POST requests carrying an X-HTTP-Method-Override
+	// header be routed as PUT, PATCH or DELETE, for clients such as HTML forms
+	// that cannot send those methods.
+	AllowMethodOverride bool
+
+	// AllowTrace enables the TRACE method, which echoes the request back.
+	// It is off by default because echoed headers can be abused for
+	// cross-site tracing.
+	AllowTrace bool
+
+	// AutoOptions answers OPTIONS requests for paths without an OPTIONS route
+	// with a 204 listing the path's methods in Allow. The response still
+	// passes through middleware, so CORS can turn it into a preflight reply.
+	AutoOptions bool
+
+	// AddServerTiming adds a Server-Timing header reporting how long the
+	// handler took, in milliseconds.
+	AddServerTiming bool
+
+	// HandlerTimeout bounds how long a routed handler may run before the
+	// client gets a 503. The request context is cancelled at the deadline so
+	// the handler can stop early. Routes may override it with
+	// router.Route.WithTimeout. Zero means no limit.
+	HandlerTimeout time.Duration
+
+	// FlushInterval makes streamed responses buffer their writes and flush
+	// them at this interval, or when the handler calls Flush. Zero flushes
+	// every write. See response.Writer.FlushInterval.
+	FlushInterval time.Duration
+
+	// ConnState, if set, is called whenever a client connection changes state.
+	ConnState func(conn net.Conn, state ConnState)
+
+	// MaxConnections caps the number of open client connections. When it is
+	// reached, the connection idle for the longest is closed to make room;
+	// if none is idle, the new connection is refused. Zero means no limit.
+	MaxConnections int
+
+	// HeaderFilter, if set, limits which request headers are stored, saving
+	// allocations for requests with many irrelevant headers. Headers the
+	// server itself consults, such as Connection, are only honoured if kept.
+	HeaderFilter func(key string) bool
+
+	// MaxRequestLineBytes bounds the request line, answering longer ones
+	// with 414 URI Too Long, and MaxHeaderBytes bounds the header block,
+	// answering larger ones with 431. Zero means the request package
+	// defaults.
+	MaxRequestLineBytes int
+	MaxHeaderBytes      int
+
+	// AllowHTTP09 accepts HTTP/0.9 simple requests ("GET /path" with no
+	// version or headers) from ancient clients. They are answered with the
+	// bare response body, without status line or headers, and the
+	// connection is then closed. Handlers cannot stream to such requests
+	// through response.WriterFromContext.
+	AllowHTTP09 bool
+
+	// Logger receives the server's error log, including the full detail of
+	// internal errors whose text is hidden from clients. If nil, the standard
+	// logger is used.
+	Logger *log.Logger
+
+	// TrustedProxies lists the reverse proxies, parsed with
+	// request.ParseNetworks, whose X-Forwarded-For headers are believed by
+	// Request.ClientIP.
+	TrustedProxies []*net.IPNet
+
+	// ForwardedProtoHeader names the header in which trusted proxies report
+	// the client's scheme, as read by Request.Scheme. Empty means
+	// X-Forwarded-Proto.
+	ForwardedProtoHeader string
+
+	// MaxConcurrentUploads bounds how many requests with large bodies are
+	// served at once. Uploads beyond the limit are refused with 503 rather
+	// than queued, so their bodies are never read; smaller requests are not
+	// affected. Zero means no limit.
+	MaxConcurrentUploads int
+
+	// UploadThreshold is the declared Content-Length from which a request
+	// counts towards MaxConcurrentUploads. Chunked bodies, whose size is not
+	// known in advance, always count. Zero counts every request with a body.
+	UploadThreshold int64
+
+	// CompressionLevel, if non-zero, gzip-compresses responses for clients
+	// that accept it, using middleware.GzipWithOptions at this level:
+	// gzip.BestSpeed to gzip.BestCompression, or gzip.DefaultCompression.
+	// Compression applies outside all middleware added with Use. An invalid
+	// level makes Serve fail.
+	CompressionLevel int
+
+	// CompressionMinLength is the smallest Content-Length compressed when
+	// CompressionLevel is set. Zero means 1024 bytes; a negative value
+	// compresses every body. Bodies of unknown length are always compressed.
+	CompressionMinLength int
+
+	// SlowRequestThreshold, if positive, logs every request that takes at
+	// least this long, from routing until the response has been sent, to the
+	// server's logger in access log format with its duration appended. Fast
+	// requests are not logged. See middleware.SlowAccessLog.
+	SlowRequestThreshold time.Duration
+
+	// DefaultHeaders are added to every response, e.g. for
+	// X-Content-Type-Options or Strict-Transport-Security. Headers set by
+	// the handler take precedence.
+	DefaultHeaders map[string]string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]*trackedConn
+	uploads  int
 }
 
 // New creates a new Server instance, ready to be configured.
@@ -22,80 +198,833 @@ func New(addr string) *Server {
 	return &Server{
 		addr:   addr,
 		router: router.New(),
+		conns:  make(map[net.Conn]*trackedConn),
 	}
 }
 
+// NewWithListenerFD creates a Server that accepts on a listener inherited from
+// another process, e.g. one handed over through ListenerFD during a restart.
+// The server listens on a duplicate of fd; the caller still owns fd and
+// should close it.
+func NewWithListenerFD(fd uintptr) (*Server, error) {
+	dup, err := dupFD(fd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listener file descriptor %d: %w", fd, err)
+	}
+	f := os.NewFile(dup, "rhttp-listener")
+	defer f.Close() // FileListener holds its own duplicate.
+
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconstruct listener: %w", err)
+	}
+	s := New(listener.Addr().String())
+	s.listener = listener
+	return s, nil
+}
+
 // AddRoute now uses the Handler type defined in the router package.
-func (s *Server) AddRoute(method, path string, handler router.Handler) {
-	s.router.AddRoute(method, path, handler)
+func (s *Server) AddRoute(method, path string, handler router.Handler) *router.Route {
+	return s.router.AddRoute(method, path, handler)
+}
+
+// Silence answers GET requests for paths such as /favicon.ico with an empty
+// 204, so clients probing for them stop producing 404s and log noise.
+func (s *Server) Silence(paths ...string) {
+	for _, path := range paths {
+		s.router.AddRoute("GET", path, noContent)
+	}
+}
+
+// noContent is the handler registered by Silence.
+func noContent(req *request.Request) (*response.Response, error) {
+	return response.New(204, nil), nil
+}
+
+// URL builds the path of a named route. See router.Router.URL.
+func (s *Server) URL(name string, params map[string]string) (string, error) {
+	return s.router.URL(name, params)
+}
+
+// Use registers global middleware that wraps every routed handler. The first
+// middleware registered runs outermost. Nothing is written to the client until
+// the outermost middleware returns, so middleware may inspect, modify or
+// replace the handler's response or error.
+func (s *Server) Use(mws ...middleware.Middleware) {
+	for _, mw := range mws {
+		s.middlewares = append(s.middlewares, mw)
+		s.middlewareNames = append(s.middlewareNames, runtime.FuncForPC(reflect.ValueOf(mw).Pointer()).Name())
+	}
 }
 
-// ListenAndServe starts the TCP listener and the main server loop.
+// UseNamed is like Use but registers middleware under the given names, which
+// Middlewares reports.
+func (s *Server) UseNamed(mws ...middleware.NamedMiddleware) {
+	for _, mw := range mws {
+		s.middlewares = append(s.middlewares, mw.Middleware)
+		s.middlewareNames = append(s.middlewareNames, mw.Name)
+	}
+}
+
+// Middlewares returns the names of the global middleware in the order it
+// runs, outermost first, to help debug the stack. Middleware registered with
+// Use is named after its function, such as
+// "github.com/mohdrashid9678/rhttp/middleware.CORS.func1". The gzip
+// middleware added by CompressionLevel is not listed.
+func (s *Server) Middlewares() []string {
+	return append([]string(nil), s.middlewareNames...)
+}
+
+// PreRoute registers a hook run before routing, for cheap checks such as
+// maintenance mode or a blocklist. Hooks run in registration order; the first
+// to return a response has it sent as is, skipping the router, middleware
+// and any later hooks. Returning nil lets the request continue.
+func (s *Server) PreRoute(hook func(req *request.Request) *response.Response) {
+	s.preRoute = append(s.preRoute, hook)
+}
+
+// Fallback sets a handler for every request no route matches, whatever its
+// method, instead of answering 404, e.g. to proxy unknown paths to another
+// service. It runs behind the server's middleware like any routed handler.
+// With AutoOptions, OPTIONS requests for routed paths are still answered by
+// the server.
+func (s *Server) Fallback(handler router.Handler) {
+	s.fallback = handler
+}
+
+// OnError registers fn to be told about every error a routed handler or its
+// middleware returns, including panics as *PanicError, e.g. to forward them
+// to an error tracker. Callbacks run in registration order, before the error
+// response is sent, and do not affect it; see ErrorRenderer for that.
+func (s *Server) OnError(fn func(req *request.Request, err error)) {
+	s.onError = append(s.onError, fn)
+}
+
+// ListenAndServe starts the TCP listener and the main server loop. A Server
+// built with NewWithListenerFD serves on its inherited listener instead.
 func (s *Server) ListenAndServe() error {
-	listener, err := net.Listen("tcp", s.addr)
-	if err != nil {
+	s.mu.Lock()
+	listener := s.listener
+	s.mu.Unlock()
+
+	if listener == nil {
+		var err error
+		if listener, err = net.Listen("tcp", s.addr); err != nil {
+			return err
+		}
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener until it is closed or fails with a
+// permanent error. Temporary accept errors, such as running out of file
+// descriptors, are retried with an increasing delay.
+func (s *Server) Serve(listener net.Listener) error {
+	if _, err := s.builtinMiddleware(); err != nil {
+		listener.Close()
+		return err
+	}
+	if _, err := s.errorPages(); err != nil {
+		listener.Close()
 		return err
 	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
 	defer listener.Close()
 
+	var tempDelay time.Duration
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Printf("failed to accept connection: %v", err)
+			if !isTemporary(err) {
+				return err
+			}
+			if tempDelay == 0 {
+				tempDelay = 5 * time.Millisecond
+			} else if tempDelay *= 2; tempDelay > time.Second {
+				tempDelay = time.Second
+			}
+			s.logf("accept error: %v; retrying in %v", err, tempDelay)
+			time.Sleep(tempDelay)
+			continue
+		}
+		tempDelay = 0
+		if !s.admit(conn) {
+			s.logf("connection limit of %d reached; refusing %v", s.MaxConnections, conn.RemoteAddr())
+			conn.Close()
 			continue
 		}
 		go s.handleConnection(conn)
 	}
 }
 
+// isTemporary reports whether an accept error may go away on its own.
+func isTemporary(err error) bool {
+	var temp interface{ Temporary() bool }
+	return errors.As(err, &temp) && temp.Temporary()
+}
+
+// ListenerFD returns a duplicate of the listening socket's file descriptor so
+// it can be passed to a successor process. The caller owns the returned file.
+func (s *Server) ListenerFD() (*os.File, error) {
+	s.mu.Lock()
+	listener := s.listener
+	s.mu.Unlock()
+
+	if listener == nil {
+		return nil, errors.New("server is not listening")
+	}
+	filer, ok := listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("listener %T does not expose a file descriptor", listener)
+	}
+	return filer.File()
+}
+
 // handleConnection manages the entire lifecycle of a single client connection.
+// Pipelined requests are served strictly one at a time: a request is parsed
+// only after the previous response has been written and its body consumed,
+// so a client queueing many requests costs no more than the read buffer.
 func (s *Server) handleConnection(conn net.Conn) {
-	defer conn.Close()
+	s.setState(conn, StateNew)
+	defer func() {
+		conn.Close()
+		s.setState(conn, StateClosed)
+	}()
 	defer s.recoverFromPanic(conn)
 
-	req, err := request.Parse(conn)
-	if err != nil {
-		s.handleError(conn, err)
-		return
+	var tlsState *tls.ConnectionState
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		// Complete the handshake up front so every request sees its result.
+		if err := tlsConn.Handshake(); err != nil {
+			s.logf("TLS handshake error from %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+		state := tlsConn.ConnectionState()
+		tlsState = &state
 	}
 
-	handler, params := s.router.FindHandler(req.Method, req.Target)
-	req.PathParams = params
+	opened := time.Now()
+	reader := request.NewReader(conn)
+	reader.MaxBodyBytes = s.MaxBodyBytes
+	reader.HeaderFilter = s.HeaderFilter
+	reader.MaxRequestLineBytes = s.MaxRequestLineBytes
+	reader.MaxHeaderBytes = s.MaxHeaderBytes
+	reader.AllowHTTP09 = s.AllowHTTP09
+	for served := 1; ; served++ {
+		req, err := reader.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.handleParseError(conn, err)
+			}
+			return
+		}
+		req.RemoteAddr = conn.RemoteAddr().String()
+		req.TrustedProxies = s.TrustedProxies
+		req.ForwardedProtoHeader = s.ForwardedProtoHeader
+		req.TLS = tlsState
+		s.setState(conn, StateActive)
+		if req.Version == "HTTP/0.9" {
+			s.serveSimple(conn, req)
+			return
+		}
 
+		keepAlive := s.keepAlive(req)
+		if s.MaxRequestsPerConn > 0 && served >= s.MaxRequestsPerConn {
+			keepAlive = false
+		}
+		if s.KeepAliveTimeout > 0 && time.Since(opened) >= s.KeepAliveTimeout {
+			keepAlive = false
+		}
+		if !s.serveRequest(conn, req, keepAlive) {
+			return
+		}
+		s.setState(conn, StateIdle)
+	}
+}
+
+// serveRequest routes a single request and writes its response. It reports
+// whether the connection may be reused for another request, which is never
+// the case when keepAlive is false.
+func (s *Server) serveRequest(conn net.Conn, req *request.Request, keepAlive bool) bool {
+	// Handlers may replace req.Body, e.g. with CacheBody; keep the original.
+	body := req.Body
+	defer s.removeTempFiles(req)
+
+	// Streaming handlers obtain w through response.WriterFromContext.
+	w := response.NewWriter(conn)
+	w.FlushInterval = s.FlushInterval
+	s.addDefaultHeaders(w.Header())
+	setConnectionHeader(w.Header(), req, keepAlive)
+	req = req.WithContext(response.NewContext(req.Context(), w))
+
+	if s.AllowMethodOverride {
+		overrideMethod(req)
+	}
+
+	start := time.Now()
 	var resp *response.Response
-	if handler != nil {
-		resp, err = handler(req)
+	release, err := s.acquireUpload(req)
+	if err == nil {
+		// Held until the body has been drained below.
+		defer release()
+		resp, err = s.dispatch(req)
+		var timedOut *timeoutError
+		if errors.As(err, &timedOut) {
+			// The abandoned handler may still be reading the body, so the
+			// next request cannot be parsed reliably.
+			keepAlive = false
+		}
 	} else {
-		err = httperrors.NewNotFound(req.Target)
+		// Close rather than drain the refused upload.
+		keepAlive = false
+	}
+	elapsed := time.Since(start)
+
+	if w.Started() {
+		// The status line is already out, so anything returned now can only
+		// be reported, never sent.
+		if resp != nil {
+			s.logf("ignoring %d response returned after the response was streamed", resp.StatusCode)
+			if closer, ok := resp.Body.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+		if err != nil {
+			// Terminating the body would pass it off as complete; send what
+			// was written and close the connection so the client sees it cut.
+			s.logf("aborting streamed response after handler error: %v", err)
+			w.Flush()
+			return false
+		}
+		if err := w.Close(); err != nil {
+			s.logf("error finishing streamed response: %v", err)
+			return false
+		}
+		return keepAlive && closeBody(body)
 	}
 
 	if err != nil {
-		s.handleError(conn, err)
-		return
+		if resp, err = s.errorResponse(err); err != nil {
+			s.logf("could not create error response: %v", err)
+			return false
+		}
 	}
 
+	if resp.Headers == nil {
+		// Handlers may build a Response without New.
+		resp.Headers = make(map[string]string)
+	}
+	if s.AddServerTiming {
+		resp.Headers["Server-Timing"] = fmt.Sprintf("app;dur=%.1f", float64(elapsed)/float64(time.Millisecond))
+	}
+
+	s.addDefaultHeaders(resp.Headers)
+
+	// A handler may ask for the connection to be closed after its response.
+	if strings.EqualFold(resp.Headers["Connection"], "close") {
+		keepAlive = false
+	}
+	setConnectionHeader(resp.Headers, req, keepAlive)
+
 	if err := resp.Write(conn); err != nil {
-		log.Printf("error writing response: %v", err)
+		switch {
+		case errors.Is(err, response.ErrBodyRead):
+			// The framing is broken; closing is the only way to tell the client.
+			s.logf("aborting response: %v", err)
+		case errors.Is(err, response.ErrInvalidStatusCode), errors.Is(err, response.ErrContentLengthMismatch):
+			// Nothing has been sent yet, so the client can still get a 500.
+			s.handleError(conn, err)
+		default:
+			s.logf("error writing response: %v", err)
+		}
+		return false
+	}
+	return keepAlive && closeBody(body)
+}
+
+// serveSimple answers an HTTP/0.9 simple request with the bare response body;
+// closing the connection marks its end.
+func (s *Server) serveSimple(conn net.Conn, req *request.Request) {
+	defer s.removeTempFiles(req)
+	resp, err := s.dispatch(req)
+	if err != nil {
+		if resp, err = s.errorResponse(err); err != nil {
+			s.logf("could not create error response: %v", err)
+			return
+		}
+	}
+	if resp.Body == nil {
+		return
+	}
+	if closer, ok := resp.Body.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if _, err := io.Copy(conn, resp.Body); err != nil {
+		s.logf("error writing response: %v", err)
 	}
 }
 
-// handleError centralizes error response logic.
+// removeTempFiles deletes the files req's multipart uploads spilled to disk.
+func (s *Server) removeTempFiles(req *request.Request) {
+	if err := req.RemoveTempFiles(); err != nil {
+		s.logf("error removing multipart temp files: %v", err)
+	}
+}
+
+// dispatch produces the response for req, either from one of the server's
+// built-in responders or from the routed handler.
+func (s *Server) dispatch(req *request.Request) (*response.Response, error) {
+	for _, hook := range s.preRoute {
+		if resp := hook(req); resp != nil {
+			return resp, nil
+		}
+	}
+
+	switch {
+	case req.Method == "OPTIONS" && req.Target == "*":
+		return s.serverOptions(), nil
+	case req.Method == "TRACE":
+		if !s.AllowTrace {
+			return nil, httperrors.NewMethodNotAllowed(req.Method)
+		}
+		return traceResponse(req), nil
+	}
+
+	rt, params, ints := s.router.FindRouteParams(req.Method, req.RawPath)
+	req.PathParams, req.PathParamInts = params, ints
+	var handler router.Handler
+	timeout := s.HandlerTimeout
+	switch {
+	case rt != nil:
+		handler = rt.Handler()
+		if rt.Timeout > 0 {
+			timeout = rt.Timeout
+		}
+	case req.Method == "OPTIONS" && s.AutoOptions && (s.fallback == nil || len(s.router.AllowedMethods(req.RawPath)) > 0):
+		handler = s.pathOptions
+	case s.fallback != nil:
+		handler = s.fallback
+	default:
+		return nil, httperrors.NewNotFound(req.Path)
+	}
+	if timeout > 0 {
+		handler = s.withTimeout(handler, timeout)
+	}
+	builtin, err := s.builtinMiddleware()
+	if err != nil {
+		return nil, err
+	}
+	middlewares := append(builtin[:len(builtin):len(builtin)], s.middlewares...)
+	resp, err := s.safeCall(middleware.Chain(handler, middlewares...), req)
+	if err != nil {
+		for _, fn := range s.onError {
+			fn(req, err)
+		}
+	}
+	return resp, err
+}
+
+// defaultCompressionMinLength is used when Server.CompressionMinLength is zero.
+const defaultCompressionMinLength = 1024
+
+// builtinMiddleware returns the middleware configured through Server fields,
+// outermost first, which runs outside everything added with Use. It is built
+// on first use, so those fields must be set before the server starts.
+func (s *Server) builtinMiddleware() ([]middleware.Middleware, error) {
+	s.builtinOnce.Do(func() {
+		if s.SlowRequestThreshold > 0 {
+			s.builtin = append(s.builtin, middleware.SlowAccessLog(logWriter{s}, s.SlowRequestThreshold))
+		}
+		if s.CompressionLevel != 0 {
+			if _, err := gzip.NewWriterLevel(io.Discard, s.CompressionLevel); err != nil {
+				s.builtinErr = fmt.Errorf("invalid CompressionLevel: %w", err)
+				return
+			}
+			minLength := s.CompressionMinLength
+			if minLength == 0 {
+				minLength = defaultCompressionMinLength
+			}
+			s.builtin = append(s.builtin, middleware.GzipWithOptions(middleware.GzipOptions{Level: s.CompressionLevel, MinLength: minLength}))
+		}
+	})
+	return s.builtin, s.builtinErr
+}
+
+// PanicError is the error a panicking handler is turned into. OnError
+// callbacks receive it, so panics can be told apart from returned errors.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// safeCall runs handler, turning a panic into a *PanicError so the request
+// can still be answered with a 500 and reported.
+func (s *Server) safeCall(handler router.Handler, req *request.Request) (resp *response.Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			s.logf("panic recovered in handler: %v\n%s", r, stack)
+			resp, err = nil, &PanicError{Value: r, Stack: stack}
+		}
+	}()
+	return handler(req)
+}
+
+// withTimeout runs handler with a deadline, answering 503 if it passes first.
+// The handler keeps running in the background until it notices its context
+// is done, and its result is then discarded. Its writes to the connection
+// fail from that point on, and the connection is closed after the 503 since
+// the handler may still be reading the request body.
+func (s *Server) withTimeout(handler router.Handler, timeout time.Duration) router.Handler {
+	return func(req *request.Request) (*response.Response, error) {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		var tw *timeoutWriter
+		if w, ok := response.WriterFromContext(ctx); ok {
+			tw = &timeoutWriter{ResponseWriter: w, ctx: ctx}
+			ctx = response.NewContext(ctx, tw)
+		}
+
+		type result struct {
+			resp *response.Response
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			resp, err := s.safeCall(handler, req.WithContext(ctx))
+			done <- result{resp, err}
+		}()
+
+		select {
+		case res := <-done:
+			return res.resp, res.err
+		case <-ctx.Done():
+			if tw != nil {
+				// Let a write already in progress finish before the 503.
+				tw.mu.Lock()
+				tw.mu.Unlock()
+			}
+			return nil, &timeoutError{httperrors.NewServiceUnavailable(fmt.Sprintf("handler timed out after %v", timeout))}
+		}
+	}
+}
+
+// timeoutError is returned by withTimeout when the handler misses its
+// deadline. It renders as the wrapped 503.
+type timeoutError struct {
+	*httperrors.HTTPError
+}
+
+func (e *timeoutError) Unwrap() error { return e.HTTPError }
+
+// errHandlerTimedOut is returned by writes from a handler that has timed out.
+var errHandlerTimedOut = errors.New("handler timed out")
+
+// timeoutWriter is the ResponseWriter handed to a handler run by withTimeout.
+// Once ctx is done it drops every write, so an abandoned handler cannot
+// corrupt the response the server sends in its place.
+type timeoutWriter struct {
+	response.ResponseWriter
+	ctx context.Context
+
+	mu sync.Mutex
+}
+
+func (w *timeoutWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() == nil {
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() != nil {
+		return 0, errHandlerTimedOut
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) WriteJSON(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() != nil {
+		return errHandlerTimedOut
+	}
+	return w.ResponseWriter.WriteJSON(v)
+}
+
+func (w *timeoutWriter) WriteInformational(statusCode int, headers map[string]string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() != nil {
+		return errHandlerTimedOut
+	}
+	return w.ResponseWriter.WriteInformational(statusCode, headers)
+}
+
+func (w *timeoutWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() != nil {
+		return errHandlerTimedOut
+	}
+	return w.ResponseWriter.Flush()
+}
+
+// overridableMethods are the methods a POST may be rewritten to.
+var overridableMethods = map[string]bool{"PUT": true, "PATCH": true, "DELETE": true}
+
+// overrideMethod rewrites the method of a POST request that asks for one of
+// overridableMethods through the X-HTTP-Method-Override header.
+func overrideMethod(req *request.Request) {
+	if req.Method != "POST" {
+		return
+	}
+	method := strings.ToUpper(strings.TrimSpace(req.Headers["X-Http-Method-Override"]))
+	if overridableMethods[method] {
+		req.Method = method
+	}
+}
+
+// keepAlive reports whether the connection may serve another request after req.
+func (s *Server) keepAlive(req *request.Request) bool {
+	if !s.KeepAlive || req.HasHeaderToken("Connection", "close") {
+		return false
+	}
+	if req.Version == "HTTP/1.0" {
+		return req.HasHeaderToken("Connection", "keep-alive")
+	}
+	return true
+}
+
+// addDefaultHeaders adds the DefaultHeaders that are not already set.
+func (s *Server) addDefaultHeaders(headers map[string]string) {
+	for key, value := range s.DefaultHeaders {
+		if _, ok := headers[key]; !ok {
+			headers[key] = value
+		}
+	}
+}
+
+// setConnectionHeader tells the client whether the connection stays open.
+func setConnectionHeader(headers map[string]string, req *request.Request, keepAlive bool) {
+	switch {
+	case !keepAlive:
+		headers["Connection"] = "close"
+	case req.Version == "HTTP/1.0":
+		headers["Connection"] = "keep-alive"
+	}
+}
+
+// closeBody closes the request body as parsed, which skips whatever the
+// handler left unread so the next request on the connection starts at the
+// right byte. It reports whether that succeeded.
+func closeBody(body io.Closer) bool {
+	return body.Close() == nil
+}
+
+// acquireUpload reserves one of the MaxConcurrentUploads slots if req has a
+// large body, failing with 503 when none is free. The returned func releases
+// the slot.
+func (s *Server) acquireUpload(req *request.Request) (func(), error) {
+	if s.MaxConcurrentUploads <= 0 || !s.isUpload(req) {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.uploads >= s.MaxConcurrentUploads {
+		return nil, httperrors.NewServiceUnavailable("too many uploads in progress")
+	}
+	s.uploads++
+	return func() {
+		s.mu.Lock()
+		s.uploads--
+		s.mu.Unlock()
+	}, nil
+}
+
+// isUpload reports whether req's body is large enough to count towards
+// MaxConcurrentUploads.
+func (s *Server) isUpload(req *request.Request) bool {
+	if req.Headers["Transfer-Encoding"] != "" {
+		return true
+	}
+	length, err := strconv.ParseInt(req.Headers["Content-Length"], 10, 64)
+	return err == nil && length > 0 && length >= s.UploadThreshold
+}
+
+// serverOptions answers an asterisk-form "OPTIONS *" request, which asks about
+// the server as a whole rather than any one resource.
+func (s *Server) serverOptions() *response.Response {
+	methods := s.router.Methods()
+	if i := sort.SearchStrings(methods, "OPTIONS"); i == len(methods) || methods[i] != "OPTIONS" {
+		methods = append(methods, "OPTIONS")
+		sort.Strings(methods)
+	}
+	resp := response.New(204, nil)
+	resp.Headers["Allow"] = strings.Join(methods, ", ")
+	return resp
+}
+
+// pathOptions answers OPTIONS for a path with the methods routed for it.
+func (s *Server) pathOptions(req *request.Request) (*response.Response, error) {
+	methods := s.router.AllowedMethods(req.RawPath)
+	if len(methods) == 0 {
+		return nil, httperrors.NewNotFound(req.Path)
+	}
+	resp := response.New(204, nil)
+	resp.Headers["Allow"] = strings.Join(methods, ", ")
+	return resp, nil
+}
+
+// logf writes to the server's logger.
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// logWriter writes to the server's logger, one entry per write.
+type logWriter struct{ s *Server }
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.s.logf("%s", p)
+	return len(p), nil
+}
+
+// describeError formats err followed by each error it wraps, with their types.
+func describeError(err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v (%T)", err, err)
+	writeCauses(&b, err, 1)
+	return b.String()
+}
+
+// writeCauses writes the errors wrapped by err, indented by depth.
+func writeCauses(b *strings.Builder, err error, depth int) {
+	var causes []error
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if cause := u.Unwrap(); cause != nil {
+			causes = []error{cause}
+		}
+	case interface{ Unwrap() []error }:
+		causes = u.Unwrap()
+	}
+	for _, cause := range causes {
+		fmt.Fprintf(b, "\n%scaused by: %v (%T)", strings.Repeat("\t", depth), cause, cause)
+		writeCauses(b, cause, depth+1)
+	}
+}
+
+// errorResponse logs err and converts it into the response sent to the client.
+// If err, or an error it wraps, has a Headers() map[string]string method,
+// those headers are added to the response, e.g. a Retry-After.
+func (s *Server) errorResponse(err error) (*response.Response, error) {
+	var withHeaders interface{ Headers() map[string]string }
+	errors.As(err, &withHeaders)
+
+	var httpErr *httperrors.HTTPError
+	if errors.As(err, &httpErr) {
+		s.logf("handler error: %v", err)
+	} else if errors.Is(err, request.ErrBodyTruncated) {
+		// The client sent less body than it declared; that is its fault.
+		s.logf("handler error: %v", err)
+		err = httperrors.NewBadRequest("request body ended before its declared Content-Length")
+	} else {
+		// The client only sees a generic 500, so this is the one place the
+		// cause is recorded.
+		s.logf("internal error: %s", describeError(err))
+	}
+	render := response.Error
+	if s.ErrorRenderer != nil {
+		render = s.ErrorRenderer
+	}
+	if pages, err := s.errorPages(); err == nil && len(pages) > 0 {
+		render = s.renderErrorPage(pages, render)
+	}
+	resp, renderErr := render(err)
+	if renderErr != nil || withHeaders == nil {
+		return resp, renderErr
+	}
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	for key, value := range withHeaders.Headers() {
+		resp.Headers[key] = value
+	}
+	return resp, nil
+}
+
+// traceResponse echoes the request line and headers back to the client.
+func traceResponse(req *request.Request) *response.Response {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s\r\n", req.Method, req.Target, req.Version)
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, req.Headers[name])
+	}
+
+	body := b.String()
+	resp := response.New(200, strings.NewReader(body))
+	resp.Headers["Content-Type"] = "message/http"
+	resp.Headers["Content-Length"] = strconv.Itoa(len(body))
+	return resp
+}
+
+// handleError sends an error response on a connection that is about to close.
 func (s *Server) handleError(conn net.Conn, err error) {
-	log.Printf("handler error: %v", err)
-	resp, writeErr := response.Error(err)
+	resp, writeErr := s.errorResponse(err)
 	if writeErr != nil {
-		log.Printf("could not create error response: %v", writeErr)
+		s.logf("could not create error response: %v", writeErr)
 		return
 	}
+	s.addDefaultHeaders(resp.Headers)
+	resp.Headers["Connection"] = "close"
 	if err := resp.Write(conn); err != nil {
-		log.Printf("error sending error response: %v", err)
+		s.logf("error sending error response: %v", err)
+	}
+}
+
+// handleParseError answers a request that could not be parsed. The parser's
+// message may echo client input or reveal internals, so it is only logged;
+// the client, and ErrorRenderer, get an error carrying just the status text.
+// Any other error means reading the request failed, e.g. on a timeout or a
+// reset in the middle of the headers, so the connection is closed without a
+// response.
+func (s *Server) handleParseError(conn net.Conn, err error) {
+	var httpErr *httperrors.HTTPError
+	if !errors.As(err, &httpErr) {
+		s.logf("read error from %v: %v", conn.RemoteAddr(), err)
+		return
 	}
+	s.logf("parse error: %v", err)
+	s.handleError(conn, &httperrors.HTTPError{StatusCode: httpErr.StatusCode, Message: http.StatusText(httpErr.StatusCode)})
 }
 
 // recoverFromPanic is a middleware to prevent a single request from crashing the server.
 func (s *Server) recoverFromPanic(conn net.Conn) {
 	if r := recover(); r != nil {
-		log.Printf("panic recovered in handleConnection: %v\n%s", r, debug.Stack())
+		s.logf("panic recovered in handleConnection: %v\n%s", r, debug.Stack())
 		s.handleError(conn, httperrors.NewInternalServerError("an unexpected error occurred"))
 	}
 }
@@ -0,0 +1,1465 @@
+package rhttp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/httperrors"
+	"github.com/mohdrashid9678/rhttp/middleware"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/mohdrashid9678/rhttp/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTrip feeds a raw request to the server over an in-memory connection
+// and returns everything the server wrote before closing it.
+func roundTrip(t *testing.T, s *Server, raw string) string {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go s.handleConnection(serverConn)
+	go func() {
+		// The write may fail once the server closes its side; that is fine.
+		clientConn.Write([]byte(raw))
+	}()
+
+	out, err := io.ReadAll(clientConn)
+	require.NoError(t, err)
+	return string(out)
+}
+
+// dial serves an in-memory connection and returns its client side together
+// with a reader for parsing the server's responses.
+func dial(t *testing.T, s *Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close() })
+
+	go s.handleConnection(serverConn)
+	return clientConn, bufio.NewReader(clientConn)
+}
+
+// send writes raw to conn without waiting for the server to consume it.
+func send(conn net.Conn, raw string) {
+	go conn.Write([]byte(raw))
+}
+
+// readResponse parses the next response on the connection and returns it with
+// its body fully read.
+func readResponse(t *testing.T, r *bufio.Reader) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestServerWideOptions(t *testing.T) {
+	s := New(":0")
+	called := false
+	handler := func(req *request.Request) (*response.Response, error) {
+		called = true
+		return response.Text(200, "ok")
+	}
+	s.AddRoute("GET", "/users", handler)
+	s.AddRoute("POST", "/users", handler)
+	s.AddRoute("OPTIONS", "/", handler)
+
+	out := roundTrip(t, s, "OPTIONS * HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+	assert.Contains(t, out, "HTTP/1.1 204 No Content\r\n")
+	assert.Contains(t, out, "Allow: GET, OPTIONS, POST\r\n")
+	assert.False(t, called, "OPTIONS * must not be routed through the radix tree")
+}
+
+func TestAutoOptionsWithCORS(t *testing.T) {
+	s := New(":0")
+	s.AutoOptions = true
+	s.Use(middleware.CORS(middleware.CORSOptions{AllowedOrigins: []string{"https://app.example"}}))
+	handler := func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	}
+	s.AddRoute("GET", "/users", handler)
+	s.AddRoute("POST", "/users", handler)
+
+	t.Run("Preflight", func(t *testing.T) {
+		out := roundTrip(t, s, "OPTIONS /users HTTP/1.1\r\nHost: localhost\r\n"+
+			"Origin: https://app.example\r\nAccess-Control-Request-Method: POST\r\n\r\n")
+
+		assert.Contains(t, out, "HTTP/1.1 204 No Content\r\n")
+		assert.Contains(t, out, "Allow: GET, POST\r\n")
+		assert.Contains(t, out, "Access-Control-Allow-Origin: https://app.example\r\n")
+		assert.Contains(t, out, "Access-Control-Allow-Methods: GET, POST\r\n")
+	})
+
+	t.Run("Plain OPTIONS", func(t *testing.T) {
+		out := roundTrip(t, s, "OPTIONS /users HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+		assert.Contains(t, out, "HTTP/1.1 204 No Content\r\n")
+		assert.Contains(t, out, "Allow: GET, POST\r\n")
+		assert.NotContains(t, out, "Access-Control-")
+	})
+
+	t.Run("Unknown path", func(t *testing.T) {
+		out := roundTrip(t, s, "OPTIONS /missing HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		assert.Contains(t, out, "HTTP/1.1 404 Not Found\r\n")
+	})
+}
+
+func TestListenerFDHandoff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	old := New(listener.Addr().String())
+	old.listener = listener
+
+	f, err := old.ListenerFD()
+	require.NoError(t, err)
+	defer f.Close()
+
+	// The old server stops accepting; the inherited descriptor keeps the socket open.
+	require.NoError(t, listener.Close())
+
+	successor, err := NewWithListenerFD(f.Fd())
+	require.NoError(t, err)
+	assert.Equal(t, listener.Addr().String(), successor.addr)
+	successor.AddRoute("GET", "/health", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "handed over")
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- successor.ListenAndServe() }()
+
+	conn, err := net.Dial("tcp", successor.addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /health HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, string(out), "handed over")
+
+	successor.mu.Lock()
+	require.NoError(t, successor.listener.Close())
+	successor.mu.Unlock()
+	assert.ErrorIs(t, <-done, net.ErrClosed)
+}
+
+func TestConnStateTransitions(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	})
+
+	var mu sync.Mutex
+	var states []ConnState
+	s.ConnState = func(conn net.Conn, state ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, state)
+	}
+
+	roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+	// The client sees EOF before the deferred StateClosed callback runs.
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(states) == 3
+	}, time.Second, time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []ConnState{StateNew, StateActive, StateClosed}, states)
+}
+
+func TestStreamingHandler(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/events", func(req *request.Request) (*response.Response, error) {
+		w, ok := response.WriterFromContext(req.Context())
+		if !ok {
+			return nil, errors.New("no response writer in context")
+		}
+		w.Header()["Content-Type"] = "text/plain"
+		w.WriteString("one\n")
+		w.WriteString("two\n")
+		return nil, nil
+	})
+
+	out := roundTrip(t, s, "GET /events HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+	assert.Contains(t, out, "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, out, "Transfer-Encoding: chunked\r\n")
+	assert.True(t, strings.HasSuffix(out, "4\r\none\n\r\n4\r\ntwo\n\r\n0\r\n\r\n"), "unexpected body: %q", out)
+}
+
+func TestStreamingHandlerReturningResponse(t *testing.T) {
+	var logs bytes.Buffer
+	s := New(":0")
+	s.Logger = log.New(&logs, "", 0)
+	s.AddRoute("GET", "/events", func(req *request.Request) (*response.Response, error) {
+		w, _ := response.WriterFromContext(req.Context())
+		w.WriteString("streamed")
+		return response.Text(201, "returned")
+	})
+
+	out := roundTrip(t, s, "GET /events HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+	assert.Equal(t, 1, strings.Count(out, "HTTP/1.1 "), "status line written more than once: %q", out)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.True(t, strings.HasSuffix(out, "8\r\nstreamed\r\n0\r\n\r\n"), "unexpected body: %q", out)
+	assert.NotContains(t, out, "returned")
+	assert.Contains(t, logs.String(), "ignoring 201 response returned after the response was streamed")
+}
+
+func TestStreamingHandlerFailureAbortsResponse(t *testing.T) {
+	s := New(":0")
+	s.Logger = log.New(io.Discard, "", 0)
+	s.KeepAlive = true
+	s.HandlerTimeout = 20 * time.Millisecond
+	s.AddRoute("GET", "/error", func(req *request.Request) (*response.Response, error) {
+		w, _ := response.WriterFromContext(req.Context())
+		w.WriteString("partial")
+		return nil, errors.New("lost the upstream")
+	})
+	s.AddRoute("GET", "/timeout", func(req *request.Request) (*response.Response, error) {
+		w, _ := response.WriterFromContext(req.Context())
+		w.WriteString("partial")
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	for _, path := range []string{"/error", "/timeout"} {
+		t.Run(path, func(t *testing.T) {
+			// roundTrip returns once the server closes the connection.
+			out := roundTrip(t, s, "GET "+path+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+			assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"), out)
+			assert.True(t, strings.HasSuffix(out, "7\r\npartial\r\n"), "the body must not be terminated: %q", out)
+		})
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.AddRoute("POST", "/echo", func(req *request.Request) (*response.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, string(body))
+	})
+	s.AddRoute("GET", "/ignore-body", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ignored")
+	})
+
+	conn, r := dial(t, s)
+
+	send(conn, "POST /echo HTTP/1.1\r\nContent-Length: 5\r\n\r\nfirst")
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "first", body)
+	assert.False(t, resp.Close)
+
+	// An unread body must not leak into the next request.
+	send(conn, "GET /ignore-body HTTP/1.1\r\nContent-Length: 4\r\n\r\njunk")
+	_, body = readResponse(t, r)
+	assert.Equal(t, "ignored", body)
+
+	send(conn, "POST /echo HTTP/1.1\r\nContent-Length: 6\r\n\r\nsecond")
+	_, body = readResponse(t, r)
+	assert.Equal(t, "second", body)
+}
+
+func TestConnectionCloseRequest(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "bye")
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "GET / HTTP/1.1\r\nConnection: close\r\n\r\n")
+
+	resp, body := readResponse(t, r)
+	assert.Equal(t, "bye", body)
+	assert.True(t, resp.Close, "response should carry Connection: close")
+
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "server should close the connection")
+}
+
+func TestHandlerRequestsConnectionClose(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.AddRoute("GET", "/stay", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "stay")
+	})
+	s.AddRoute("GET", "/leave", func(req *request.Request) (*response.Response, error) {
+		resp, err := response.Text(200, "leave")
+		resp.Headers["Connection"] = "Close"
+		return resp, err
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "GET /stay HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, _ := readResponse(t, r)
+	assert.False(t, resp.Close)
+
+	send(conn, "GET /leave HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Equal(t, "leave", body)
+	assert.True(t, resp.Close, "response should carry Connection: close")
+
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "server should close the connection")
+}
+
+func TestMethodOverride(t *testing.T) {
+	newServer := func() *Server {
+		s := New(":0")
+		s.AddRoute("PUT", "/items/1", func(req *request.Request) (*response.Response, error) {
+			return response.Text(200, "put")
+		})
+		s.AddRoute("POST", "/items/1", func(req *request.Request) (*response.Response, error) {
+			return response.Text(200, "post")
+		})
+		return s
+	}
+	const raw = "POST /items/1 HTTP/1.1\r\nX-HTTP-Method-Override: PUT\r\n\r\n"
+
+	t.Run("Off by default", func(t *testing.T) {
+		out := roundTrip(t, newServer(), raw)
+		assert.True(t, strings.HasSuffix(out, "\r\n\r\npost"), out)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		s := newServer()
+		s.AllowMethodOverride = true
+		out := roundTrip(t, s, raw)
+		assert.True(t, strings.HasSuffix(out, "\r\n\r\nput"), out)
+	})
+
+	t.Run("Unsafe override ignored", func(t *testing.T) {
+		s := newServer()
+		s.AllowMethodOverride = true
+		out := roundTrip(t, s, "POST /items/1 HTTP/1.1\r\nX-HTTP-Method-Override: CONNECT\r\n\r\n")
+		assert.True(t, strings.HasSuffix(out, "\r\n\r\npost"), out)
+	})
+}
+
+// temporaryError is an accept error that asks the server to retry.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// scriptedListener returns the queued errors from Accept, in order.
+type scriptedListener struct {
+	net.Listener
+	errs    []error
+	accepts int
+}
+
+func (l *scriptedListener) Accept() (net.Conn, error) {
+	err := l.errs[l.accepts]
+	l.accepts++
+	return nil, err
+}
+
+func (l *scriptedListener) Close() error { return nil }
+
+func TestServeAcceptErrors(t *testing.T) {
+	permanent := errors.New("listener broken")
+	l := &scriptedListener{errs: []error{temporaryError{}, temporaryError{}, permanent}}
+
+	start := time.Now()
+	err := New(":0").Serve(l)
+
+	assert.ErrorIs(t, err, permanent)
+	assert.Equal(t, 3, l.accepts, "temporary errors should be retried")
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond, "retries should back off")
+}
+
+func TestInvalidStatusCodeBecomes500(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(2000, "oops")
+	})
+
+	out := roundTrip(t, s, "GET / HTTP/1.1\r\n\r\n")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 500 Internal Server Error\r\n"), out)
+}
+
+func TestUseMiddleware(t *testing.T) {
+	s := New(":0")
+	var order []string
+	trace := func(name string) middleware.Middleware {
+		return func(next router.Handler) router.Handler {
+			return func(req *request.Request) (*response.Response, error) {
+				order = append(order, name)
+				return next(req)
+			}
+		}
+	}
+	s.Use(trace("first"), trace("second"))
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		order = append(order, "handler")
+		return response.Text(200, "ok")
+	})
+
+	roundTrip(t, s, "GET / HTTP/1.1\r\n\r\n")
+	assert.Equal(t, []string{"first", "second", "handler"}, order)
+}
+
+func TestTrace(t *testing.T) {
+	const raw = "TRACE /debug HTTP/1.1\r\nHost: localhost\r\nX-Trace-Id: abc\r\n\r\n"
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		out := roundTrip(t, New(":0"), raw)
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 405 Method Not Allowed\r\n"), out)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		s := New(":0")
+		s.AllowTrace = true
+		out := roundTrip(t, s, raw)
+
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"), out)
+		assert.Contains(t, out, "Content-Type: message/http\r\n")
+		assert.True(t, strings.HasSuffix(out, "\r\n\r\n"+
+			"TRACE /debug HTTP/1.1\r\n"+
+			"Host: localhost\r\n"+
+			"X-Trace-Id: abc\r\n"), out)
+	})
+}
+
+func TestMaxRequestsPerConn(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.MaxRequestsPerConn = 2
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	})
+
+	conn, r := dial(t, s)
+	send(conn, strings.Repeat("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n", 3))
+
+	resp, _ := readResponse(t, r)
+	assert.False(t, resp.Close, "first response should keep the connection open")
+	resp, _ = readResponse(t, r)
+	assert.True(t, resp.Close, "last allowed response should carry Connection: close")
+
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "the third request must not be served")
+}
+
+func TestKeepAliveTimeout(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.KeepAliveTimeout = 50 * time.Millisecond
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, _ := readResponse(t, r)
+	assert.False(t, resp.Close, "a young connection is kept alive")
+
+	time.Sleep(60 * time.Millisecond)
+	send(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Equal(t, "ok", body, "the request is still served")
+	assert.True(t, resp.Close, "the response past the timeout should carry Connection: close")
+
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "the connection should then be closed")
+}
+
+func TestServerTiming(t *testing.T) {
+	newServer := func() *Server {
+		s := New(":0")
+		s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+			time.Sleep(2 * time.Millisecond)
+			return response.Text(200, "ok")
+		})
+		return s
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		out := roundTrip(t, newServer(), "GET / HTTP/1.1\r\n\r\n")
+		assert.NotContains(t, out, "Server-Timing")
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		s := newServer()
+		s.AddServerTiming = true
+		out := roundTrip(t, s, "GET / HTTP/1.1\r\n\r\n")
+		assert.Regexp(t, `\r\nServer-Timing: app;dur=\d+\.\d\r\n`, out)
+	})
+}
+
+// brokenReader yields some bytes and then fails, like a file hitting a read error.
+type brokenReader struct {
+	sent bool
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	if b.sent {
+		return 0, errors.New("read error")
+	}
+	b.sent = true
+	return copy(p, "partial"), nil
+}
+
+func TestBodyReadErrorClosesConnection(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.AddRoute("GET", "/file", func(req *request.Request) (*response.Response, error) {
+		return response.New(200, &brokenReader{}), nil
+	})
+
+	conn, r := dial(t, s)
+	send(conn, strings.Repeat("GET /file HTTP/1.1\r\nHost: localhost\r\n\r\n", 2))
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err, "the server should close the connection")
+	assert.Equal(t, 0, strings.Count(string(out), "0\r\n\r\n"), "the broken body must not be terminated")
+	assert.LessOrEqual(t, strings.Count(string(out), "HTTP/1.1 "), 1, "the second request must not be served")
+}
+
+// jsonErrors renders errors as JSON objects.
+func jsonErrors(err error) (*response.Response, error) {
+	status, message := 500, "internal error"
+	var httpErr *httperrors.HTTPError
+	if errors.As(err, &httpErr) {
+		status, message = httpErr.StatusCode, httpErr.Message
+	}
+	return response.JSON(status, map[string]interface{}{"status": status, "error": message})
+}
+
+func TestErrorRendererForLimitErrors(t *testing.T) {
+	s := New(":0")
+	s.MaxBodyBytes = 10
+	s.ErrorRenderer = jsonErrors
+	s.AddRoute("POST", "/upload", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "stored")
+	})
+
+	t.Run("413", func(t *testing.T) {
+		out := roundTrip(t, s, "POST /upload HTTP/1.1\r\nContent-Length: 100\r\n\r\n"+strings.Repeat("x", 100))
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 413 Content Too Large\r\n"), out)
+		assert.Contains(t, out, "Content-Type: application/json; charset=utf-8\r\n")
+		assert.Contains(t, out, `"status":413`)
+	})
+
+	t.Run("431", func(t *testing.T) {
+		out := roundTrip(t, s, "GET / HTTP/1.1\r\nX-Big: "+strings.Repeat("a", request.DefaultMaxHeaderBytes)+"\r\n\r\n")
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 431 Request Header Fields Too Large\r\n"), out)
+		assert.Contains(t, out, `"status":431`)
+	})
+
+	t.Run("Within limit", func(t *testing.T) {
+		out := roundTrip(t, s, "POST /upload HTTP/1.1\r\nContent-Length: 4\r\n\r\ndata")
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"), out)
+	})
+}
+
+func TestMaxConnectionsEvictsOldestIdle(t *testing.T) {
+	s := New("127.0.0.1:0")
+	s.KeepAlive = true
+	s.MaxConnections = 2
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(listener)
+	defer listener.Close()
+
+	idle := func() int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		n := 0
+		for _, tc := range s.conns {
+			if tc.state == StateIdle {
+				n++
+			}
+		}
+		return n
+	}
+	// open makes a request on a new connection and waits until the server
+	// considers it idle.
+	open := func(wantIdle int) (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		r := bufio.NewReader(conn)
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		readResponse(t, r)
+		require.Eventually(t, func() bool { return idle() == wantIdle }, time.Second, time.Millisecond)
+		return conn, r
+	}
+
+	_, oldest := open(1)
+	newer, newerReader := open(2)
+	open(2) // Evicts the oldest idle connection and becomes idle itself.
+
+	_, err = oldest.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "the oldest idle connection should be closed")
+
+	_, err = newer.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	resp, _ := readResponse(t, newerReader)
+	assert.Equal(t, 200, resp.StatusCode, "the more recently idle connection stays usable")
+}
+
+func TestMiddlewareReplacesResponse(t *testing.T) {
+	// serveStale answers with a cached copy whenever the handler fails.
+	serveStale := func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			resp, err := next(req)
+			if err != nil || resp.StatusCode >= 500 {
+				cached, _ := response.Text(200, "cached copy")
+				cached.Headers["X-Cache"] = "HIT"
+				return cached, nil
+			}
+			return resp, nil
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		handler router.Handler
+	}{
+		{
+			name: "Handler returns a 500 response",
+			handler: func(req *request.Request) (*response.Response, error) {
+				return response.Text(500, "database down")
+			},
+		},
+		{
+			name: "Handler returns an error",
+			handler: func(req *request.Request) (*response.Response, error) {
+				return nil, errors.New("database down")
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(":0")
+			s.Use(serveStale)
+			s.AddRoute("GET", "/report", tc.handler)
+
+			out := roundTrip(t, s, "GET /report HTTP/1.1\r\n\r\n")
+			assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"), out)
+			assert.Contains(t, out, "X-Cache: HIT\r\n")
+			assert.True(t, strings.HasSuffix(out, "cached copy"), out)
+			assert.NotContains(t, out, "500")
+		})
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	sleep := func(d time.Duration) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			select {
+			case <-time.After(d):
+				return response.Text(200, "done")
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+	}
+
+	s := New(":0")
+	s.HandlerTimeout = 50 * time.Millisecond
+	s.AddRoute("GET", "/quick", sleep(time.Millisecond))
+	s.AddRoute("GET", "/slow", sleep(200*time.Millisecond))
+	s.AddRoute("GET", "/report", sleep(200*time.Millisecond)).WithTimeout(time.Second)
+	s.AddRoute("GET", "/strict", sleep(20*time.Millisecond)).WithTimeout(5 * time.Millisecond)
+
+	testCases := []struct {
+		path   string
+		status string
+	}{
+		{path: "/quick", status: "HTTP/1.1 200 OK\r\n"},
+		{path: "/slow", status: "HTTP/1.1 503 Service Unavailable\r\n"},
+		{path: "/report", status: "HTTP/1.1 200 OK\r\n"},
+		{path: "/strict", status: "HTTP/1.1 503 Service Unavailable\r\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			out := roundTrip(t, s, "GET "+tc.path+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+			assert.True(t, strings.HasPrefix(out, tc.status), out)
+		})
+	}
+}
+
+func TestResponseWithNilHeaders(t *testing.T) {
+	s := New(":0")
+	s.AddServerTiming = true
+	s.DefaultHeaders = map[string]string{"X-Frame-Options": "DENY"}
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return &response.Response{StatusCode: 200, Body: strings.NewReader("bare")}, nil
+	})
+
+	out := roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 "), out)
+	assert.Contains(t, out, "X-Frame-Options: DENY\r\n")
+	assert.Contains(t, out, "Connection: close\r\n")
+	assert.True(t, strings.HasSuffix(out, "bare\r\n0\r\n\r\n"), out)
+}
+
+func TestHandlerTimeoutKeepsConnectionAlive(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.HandlerTimeout = time.Second
+	s.AddRoute("POST", "/echo", func(req *request.Request) (*response.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, string(body))
+	})
+
+	conn, r := dial(t, s)
+	for _, payload := range []string{"first", "second"} {
+		send(conn, "POST /echo HTTP/1.1\r\nHost: localhost\r\nContent-Length: "+fmt.Sprint(len(payload))+"\r\n\r\n"+payload)
+		resp, body := readResponse(t, r)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.False(t, resp.Close, "a handler finishing in time must not close the connection")
+		assert.Equal(t, payload, body)
+	}
+}
+
+func TestHandlerTimeoutClosesConnection(t *testing.T) {
+	writeErr := make(chan error, 1)
+	release := make(chan struct{})
+	defer close(release)
+	s := New(":0")
+	s.HandlerTimeout = 20 * time.Millisecond
+	s.AddRoute("POST", "/slow", func(req *request.Request) (*response.Response, error) {
+		<-req.Context().Done()
+		w, _ := response.WriterFromContext(req.Context())
+		_, err := w.WriteString("too late")
+		writeErr <- err
+		<-release
+		return response.Text(200, "too late")
+	})
+	s.AddRoute("GET", "/next", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "next")
+	})
+
+	conn, r := dial(t, s)
+	// The slow handler never reads its body, and a second request follows.
+	send(conn, "POST /slow HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\n\r\nhello"+
+		"GET /next HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.True(t, resp.Close, "a timed-out request must close the connection")
+	assert.Contains(t, body, "handler timed out")
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "the connection should be closed, not left hanging")
+
+	select {
+	case err := <-writeErr:
+		assert.Error(t, err, "writes after the timeout must fail")
+	case <-time.After(time.Second):
+		t.Fatal("handler did not finish")
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	payload := strings.Repeat("compress me ", 100)
+	s := New(":0")
+	s.Use(middleware.Gzip())
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, payload)
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nAccept-Encoding: gzip\r\n\r\n")
+	resp, body := readResponse(t, r)
+
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+	assert.Less(t, len(body), len(payload))
+	gz, err := gzip.NewReader(strings.NewReader(body))
+	require.NoError(t, err)
+	plain, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(plain))
+}
+
+func TestCompressionLevel(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "record %d has weight %d\n", i, i*i%997)
+	}
+	payload := sb.String()
+
+	compressedSize := func(level int) int {
+		s := New(":0")
+		s.CompressionLevel = level
+		s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+			return response.Text(200, payload)
+		})
+		conn, r := dial(t, s)
+		send(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nAccept-Encoding: gzip\r\n\r\n")
+		resp, body := readResponse(t, r)
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		return len(body)
+	}
+
+	assert.Less(t, compressedSize(gzip.BestCompression), compressedSize(gzip.BestSpeed))
+
+	// Zero leaves compression off.
+	s := New(":0")
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, payload)
+	})
+	conn, r := dial(t, s)
+	send(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nAccept-Encoding: gzip\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, payload, body)
+
+	// An invalid level is reported once by Serve, not on every request.
+	s = New(":0")
+	s.CompressionLevel = 10
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.ErrorContains(t, s.Serve(ln), "invalid CompressionLevel")
+}
+
+func TestCompressionMinLength(t *testing.T) {
+	s := New(":0")
+	s.KeepAlive = true
+	s.CompressionLevel = gzip.BestSpeed
+	s.AddRoute("GET", "/small", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "small body")
+	})
+	s.AddRoute("GET", "/large", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, strings.Repeat("large body ", 200))
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "GET /small HTTP/1.1\r\nHost: localhost\r\nAccept-Encoding: gzip\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "small body", body)
+
+	send(conn, "GET /large HTTP/1.1\r\nHost: localhost\r\nAccept-Encoding: gzip\r\n\r\n")
+	resp, body = readResponse(t, r)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Less(t, len(body), 200*len("large body "))
+}
+
+// dbError is an internal error type whose text must never reach clients.
+type dbError struct{ query string }
+
+func (e *dbError) Error() string { return "query failed: " + e.query }
+
+func TestInternalErrorsAreLoggedNotSent(t *testing.T) {
+	var logs bytes.Buffer
+	s := New(":0")
+	s.Logger = log.New(&logs, "", 0)
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		cause := &dbError{query: "SELECT secret FROM users"}
+		return nil, fmt.Errorf("loading profile: %w", cause)
+	})
+
+	out := roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+	assert.Contains(t, out, "HTTP/1.1 500 Internal Server Error\r\n")
+	_, body, _ := strings.Cut(out, "\r\n\r\n")
+	assert.Equal(t, "Internal Server Error", body)
+	for _, secret := range []string{"loading profile", "SELECT", "secret", "query failed"} {
+		assert.NotContains(t, out, secret)
+	}
+
+	logged := logs.String()
+	assert.Contains(t, logged, "loading profile: query failed: SELECT secret FROM users")
+	assert.Contains(t, logged, "caused by: query failed: SELECT secret FROM users (*rhttp.dbError)")
+}
+
+func TestTruncatedBodyIsBadRequest(t *testing.T) {
+	s := New(":0")
+	s.Logger = log.New(io.Discard, "", 0)
+	resp, err := s.errorResponse(fmt.Errorf("reading upload: %w", request.ErrBodyTruncated))
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	// A handler's own unexpected EOF, e.g. from a truncated file, is not the
+	// client's fault.
+	resp, err = s.errorResponse(fmt.Errorf("reading template: %w", io.ErrUnexpectedEOF))
+	require.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+}
+
+func TestReadErrorClosesWithoutResponse(t *testing.T) {
+	var logs bytes.Buffer
+	s := New(":0")
+	s.Logger = log.New(&logs, "", 0)
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	serverConn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	go s.handleConnection(serverConn)
+
+	// The headers never finish, so the read times out.
+	send(clientConn, "GET / HTTP/1.1\r\nHost: loc")
+	out, err := io.ReadAll(clientConn)
+	require.NoError(t, err)
+	assert.Empty(t, out, "a failed read must not be answered with a 500")
+	assert.Contains(t, logs.String(), "read error")
+}
+
+func TestPipelinedRequestsAreSequential(t *testing.T) {
+	release := make(chan struct{})
+	secondStarted := make(chan struct{})
+	s := New(":0")
+	s.KeepAlive = true
+	s.AddRoute("POST", "/first", func(req *request.Request) (*response.Response, error) {
+		<-release
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, "first:"+string(body))
+	})
+	s.AddRoute("POST", "/second", func(req *request.Request) (*response.Response, error) {
+		close(secondStarted)
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, "second:"+string(body))
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "POST /first HTTP/1.1\r\nHost: localhost\r\nContent-Length: 3\r\n\r\nabc"+
+		"POST /second HTTP/1.1\r\nHost: localhost\r\nContent-Length: 3\r\n\r\nxyz")
+
+	select {
+	case <-secondStarted:
+		t.Fatal("the second request was handled before the first finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	_, body := readResponse(t, r)
+	assert.Equal(t, "first:abc", body)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "second:xyz", body)
+}
+
+func TestSilence(t *testing.T) {
+	var logs bytes.Buffer
+	s := New(":0")
+	s.Logger = log.New(&logs, "", 0)
+	s.Silence("/favicon.ico", "/robots.txt")
+
+	for _, path := range []string{"/favicon.ico", "/robots.txt"} {
+		out := roundTrip(t, s, "GET "+path+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		assert.Contains(t, out, "HTTP/1.1 204 No Content\r\n")
+	}
+	assert.Empty(t, logs.String(), "silenced paths must not be logged")
+
+	out := roundTrip(t, s, "GET /other HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Contains(t, out, "HTTP/1.1 404 Not Found\r\n")
+	assert.Contains(t, logs.String(), "not found")
+}
+
+func TestOnError(t *testing.T) {
+	type report struct {
+		target string
+		err    error
+	}
+	var reports []report
+	var order []string
+
+	s := New(":0")
+	s.Logger = log.New(io.Discard, "", 0)
+	s.OnError(func(req *request.Request, err error) {
+		order = append(order, "first")
+		reports = append(reports, report{target: req.Target, err: err})
+	})
+	s.OnError(func(req *request.Request, err error) {
+		order = append(order, "second")
+	})
+	errBoom := errors.New("boom")
+	s.AddRoute("GET", "/error", func(req *request.Request) (*response.Response, error) {
+		return nil, errBoom
+	})
+	s.AddRoute("GET", "/panic", func(req *request.Request) (*response.Response, error) {
+		panic("kaboom")
+	})
+	s.AddRoute("GET", "/ok", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	})
+
+	out := roundTrip(t, s, "GET /error HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Contains(t, out, "HTTP/1.1 500 Internal Server Error\r\n")
+	out = roundTrip(t, s, "GET /panic HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Contains(t, out, "HTTP/1.1 500 Internal Server Error\r\n")
+	roundTrip(t, s, "GET /ok HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+	require.Len(t, reports, 2, "only failing requests are reported")
+	assert.Equal(t, "/error", reports[0].target)
+	assert.ErrorIs(t, reports[0].err, errBoom)
+	assert.Equal(t, "/panic", reports[1].target)
+	var panicErr *PanicError
+	require.ErrorAs(t, reports[1].err, &panicErr)
+	assert.Equal(t, "kaboom", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.Equal(t, []string{"first", "second", "first", "second"}, order)
+}
+
+func TestUnsupportedHTTPVersion(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	})
+
+	for _, version := range []string{"HTTP/2.0", "HTTP/0.9"} {
+		t.Run(version, func(t *testing.T) {
+			out := roundTrip(t, s, "GET / "+version+"\r\nHost: localhost\r\n\r\n")
+			resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(out)), nil)
+			require.NoError(t, err)
+			assert.Equal(t, 505, resp.StatusCode)
+			assert.True(t, resp.Close, "connection should be closed")
+		})
+	}
+
+	out := roundTrip(t, s, "GET / HTTP/1.0\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+}
+
+func TestMaxConcurrentUploads(t *testing.T) {
+	s := New(":0")
+	s.MaxConcurrentUploads = 1
+	s.UploadThreshold = 10
+	reading := make(chan struct{})
+	release := make(chan struct{})
+	s.AddRoute("POST", "/upload", func(req *request.Request) (*response.Response, error) {
+		close(reading)
+		<-release
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, fmt.Sprintf("got %d bytes", len(body)))
+	})
+	s.AddRoute("POST", "/small", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "small")
+	})
+
+	upload := "POST /upload HTTP/1.1\r\nHost: localhost\r\nContent-Length: 20\r\n\r\n01234567890123456789"
+	first, firstReader := dial(t, s)
+	send(first, upload)
+	<-reading
+
+	t.Run("Second upload refused", func(t *testing.T) {
+		second, secondReader := dial(t, s)
+		send(second, upload)
+		resp, _ := readResponse(t, secondReader)
+		assert.Equal(t, 503, resp.StatusCode)
+		assert.True(t, resp.Close)
+	})
+
+	t.Run("Small request unaffected", func(t *testing.T) {
+		out := roundTrip(t, s, "POST /small HTTP/1.1\r\nHost: localhost\r\nContent-Length: 2\r\n\r\nhi")
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	})
+
+	close(release)
+	resp, body := readResponse(t, firstReader)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "got 20 bytes", body)
+
+	t.Run("Slot freed after upload", func(t *testing.T) {
+		reading = make(chan struct{})
+		out := roundTrip(t, s, strings.Replace(upload, "HTTP/1.1\r\n", "HTTP/1.1\r\nConnection: close\r\n", 1))
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	})
+}
+
+// retryError is a handler error that asks the client to retry later.
+type retryError struct {
+	cause      error
+	retryAfter string
+}
+
+func (e *retryError) Error() string { return e.cause.Error() }
+func (e *retryError) Unwrap() error { return e.cause }
+func (e *retryError) Headers() map[string]string {
+	return map[string]string{"Retry-After": e.retryAfter}
+}
+
+func TestErrorHeaders(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/busy", func(req *request.Request) (*response.Response, error) {
+		return nil, &retryError{cause: httperrors.NewServiceUnavailable("try again soon"), retryAfter: "30"}
+	})
+	s.AddRoute("GET", "/wrapped", func(req *request.Request) (*response.Response, error) {
+		err := &retryError{cause: errors.New("backend down"), retryAfter: "5"}
+		return nil, fmt.Errorf("loading: %w", err)
+	})
+
+	testCases := []struct {
+		path       string
+		status     int
+		retryAfter string
+	}{
+		{path: "/busy", status: 503, retryAfter: "30"},
+		{path: "/wrapped", status: 500, retryAfter: "5"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			conn, r := dial(t, s)
+			send(conn, "GET "+tc.path+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+			resp, _ := readResponse(t, r)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.Equal(t, tc.retryAfter, resp.Header.Get("Retry-After"))
+		})
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	s := New(":0")
+	s.DefaultHeaders = map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+	}
+	s.AddRoute("GET", "/plain", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "plain")
+	})
+	s.AddRoute("GET", "/embeddable", func(req *request.Request) (*response.Response, error) {
+		resp, err := response.Text(200, "embeddable")
+		resp.Headers["X-Frame-Options"] = "SAMEORIGIN"
+		return resp, err
+	})
+	s.AddRoute("GET", "/stream", func(req *request.Request) (*response.Response, error) {
+		w, _ := response.WriterFromContext(req.Context())
+		_, err := w.Write([]byte("streamed"))
+		return nil, err
+	})
+
+	testCases := []struct {
+		path        string
+		status      int
+		frameOption string
+	}{
+		{path: "/plain", status: 200, frameOption: "DENY"},
+		{path: "/embeddable", status: 200, frameOption: "SAMEORIGIN"},
+		{path: "/stream", status: 200, frameOption: "DENY"},
+		{path: "/missing", status: 404, frameOption: "DENY"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			conn, r := dial(t, s)
+			send(conn, "GET "+tc.path+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+			resp, _ := readResponse(t, r)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+			assert.Equal(t, tc.frameOption, resp.Header.Get("X-Frame-Options"))
+		})
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	s := New(":0")
+	var order []string
+	tag := func(name string) middleware.Middleware {
+		return func(next router.Handler) router.Handler {
+			return func(req *request.Request) (*response.Response, error) {
+				order = append(order, name)
+				return next(req)
+			}
+		}
+	}
+	s.UseNamed(middleware.Named("auth", tag("auth")), middleware.Named("metrics", tag("metrics")))
+	s.Use(middleware.Gzip())
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "ok")
+	})
+
+	assert.Equal(t, []string{"auth", "metrics", "github.com/mohdrashid9678/rhttp/middleware.GzipWithOptions.func1"}, s.Middlewares())
+
+	roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, []string{"auth", "metrics"}, order)
+}
+
+func TestIntParamRoute(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/orders/:id:int", func(req *request.Request) (*response.Response, error) {
+		assert.Equal(t, map[string]int{"id": 42}, req.PathParamInts)
+		id, err := req.ParamInt("id")
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, fmt.Sprintf("order %d", id))
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "GET /orders/42 HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "order 42", body)
+
+	conn, r = dial(t, s)
+	send(conn, "GET /orders/forty-two HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, _ = readResponse(t, r)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestRoutingIgnoresQuery(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/users/:id", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, req.Param("id")+" "+req.QueryParam("tab"))
+	})
+
+	conn, r := dial(t, s)
+	send(conn, "GET /users/42?tab=repos HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "42 repos", body)
+
+	conn, r = dial(t, s)
+	send(conn, "GET /missing?tab=repos HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body = readResponse(t, r)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "Resource '/missing' not found", body)
+}
+
+func TestRoutingDecodesParams(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/users/:name", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, req.Param("name"))
+	}).Name("user")
+
+	target, err := s.URL("user", map[string]string{"name": "John Doe/jr"})
+	require.NoError(t, err)
+	conn, r := dial(t, s)
+	send(conn, "GET "+target+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "John Doe/jr", body)
+}
+
+func TestSlowRequestThreshold(t *testing.T) {
+	var logs bytes.Buffer
+	s := New(":0")
+	s.Logger = log.New(&logs, "", 0)
+	s.SlowRequestThreshold = 20 * time.Millisecond
+	s.AddRoute("GET", "/fast", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "fast")
+	})
+	s.AddRoute("GET", "/slow", func(req *request.Request) (*response.Response, error) {
+		time.Sleep(30 * time.Millisecond)
+		return response.Text(200, "slow")
+	})
+
+	roundTrip(t, s, "GET /fast HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Zero(t, logs.Len(), "fast request logged: %q", logs.String())
+
+	roundTrip(t, s, "GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Regexp(t, `"GET /slow HTTP/1\.1" 200 4 \d+(\.\d+)?ms\n$`, logs.String())
+}
+
+func TestFallback(t *testing.T) {
+	s := New(":0")
+	s.AutoOptions = true
+	s.AddRoute("GET", "/known", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "known")
+	})
+	s.Fallback(func(req *request.Request) (*response.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return response.Text(200, fmt.Sprintf("fallback %s %s %s %s", req.Method, req.Target, req.Headers["X-Trace"], body))
+	})
+
+	testCases := []struct {
+		name string
+		raw  string
+		body string
+	}{
+		{
+			name: "Unmatched PATCH",
+			raw:  "PATCH /whatever HTTP/1.1\r\nHost: localhost\r\nX-Trace: t1\r\nContent-Length: 4\r\n\r\ndata",
+			body: "fallback PATCH /whatever t1 data",
+		},
+		{
+			name: "Unrouted method on known path",
+			raw:  "DELETE /known HTTP/1.1\r\nHost: localhost\r\n\r\n",
+			body: "fallback DELETE /known  ",
+		},
+		{
+			name: "OPTIONS on unknown path",
+			raw:  "OPTIONS /whatever HTTP/1.1\r\nHost: localhost\r\n\r\n",
+			body: "fallback OPTIONS /whatever  ",
+		},
+		{name: "Routed", raw: "GET /known HTTP/1.1\r\nHost: localhost\r\n\r\n", body: "known"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, r := dial(t, s)
+			send(conn, tc.raw)
+			resp, body := readResponse(t, r)
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Equal(t, tc.body, body)
+		})
+	}
+
+	conn, r := dial(t, s)
+	send(conn, "OPTIONS /known HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, _ := readResponse(t, r)
+	assert.Equal(t, 204, resp.StatusCode)
+	assert.Equal(t, "GET", resp.Header.Get("Allow"))
+}
+
+func TestHTTP09SimpleRequest(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/hello", func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, "hello from "+req.Version)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		s.AllowHTTP09 = true
+		defer func() { s.AllowHTTP09 = false }()
+		assert.Equal(t, "hello from HTTP/0.9", roundTrip(t, s, "GET /hello\r\n"))
+
+		// Versioned requests are unaffected.
+		out := roundTrip(t, s, "GET /hello HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"), out)
+		out = roundTrip(t, s, "GET /hello HTTP/0.9\r\nHost: localhost\r\n\r\n")
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 505 HTTP Version Not Supported\r\n"), out)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		out := roundTrip(t, s, "GET /hello\r\n")
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 400 Bad Request\r\n"), out)
+	})
+}
+
+func TestMultipartTempFilesRemovedAfterResponse(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	s := New(":0")
+	s.AddRoute("POST", "/upload", func(req *request.Request) (*response.Response, error) {
+		form, err := req.MultipartForm(16)
+		if err != nil {
+			return nil, err
+		}
+		entries, _ := os.ReadDir(tmp)
+		return response.Text(200, fmt.Sprintf("%d bytes, %d temp files", form.File["doc"][0].Size, len(entries)))
+	})
+
+	body := "--XYZ\r\nContent-Disposition: form-data; name=\"doc\"; filename=\"doc.txt\"\r\n\r\n" +
+		strings.Repeat("d", 100) + "\r\n--XYZ--\r\n"
+	conn, r := dial(t, s)
+	send(conn, fmt.Sprintf("POST /upload HTTP/1.1\r\nHost: localhost\r\nContent-Type: multipart/form-data; boundary=XYZ\r\nContent-Length: %d\r\n\r\n%s", len(body), body))
+	resp, out := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "100 bytes, 1 temp files", out)
+
+	assert.Eventually(t, func() bool {
+		entries, err := os.ReadDir(tmp)
+		return err == nil && len(entries) == 0
+	}, time.Second, 5*time.Millisecond, "temp file should be removed once the response is written")
+}
+
+func TestPreRoute(t *testing.T) {
+	s := New(":0")
+	var order []string
+	maintenance := false
+	s.PreRoute(func(req *request.Request) *response.Response {
+		order = append(order, "first")
+		if maintenance {
+			resp, _ := response.Text(503, "down for maintenance")
+			return resp
+		}
+		return nil
+	})
+	s.PreRoute(func(req *request.Request) *response.Response {
+		order = append(order, "second")
+		return nil
+	})
+	s.Use(func(next router.Handler) router.Handler {
+		return func(req *request.Request) (*response.Response, error) {
+			order = append(order, "middleware")
+			return next(req)
+		}
+	})
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		order = append(order, "handler")
+		return response.Text(200, "ok")
+	})
+
+	t.Run("Nil continues to routing", func(t *testing.T) {
+		order = nil
+		conn, r := dial(t, s)
+		send(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		resp, body := readResponse(t, r)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "ok", body)
+		assert.Equal(t, []string{"first", "second", "middleware", "handler"}, order)
+	})
+
+	t.Run("Response short-circuits", func(t *testing.T) {
+		order = nil
+		maintenance = true
+		conn, r := dial(t, s)
+		send(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		resp, body := readResponse(t, r)
+		assert.Equal(t, 503, resp.StatusCode)
+		assert.Equal(t, "down for maintenance", body)
+		assert.Equal(t, []string{"first"}, order)
+	})
+}
+
+func TestParseErrorsAreGeneric(t *testing.T) {
+	var logs bytes.Buffer
+	s := New(":0")
+	s.Logger = log.New(&logs, "", 0)
+
+	out := roundTrip(t, s, "GET /a b HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	resp, body := readResponse(t, bufio.NewReader(strings.NewReader(out)))
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "Bad Request", body)
+	assert.Contains(t, logs.String(), "malformed request line")
+
+	t.Run("ErrorRenderer", func(t *testing.T) {
+		s.ErrorRenderer = jsonErrors
+		out := roundTrip(t, s, "GET /a b HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		_, body := readResponse(t, bufio.NewReader(strings.NewReader(out)))
+		assert.JSONEq(t, `{"status":400,"error":"Bad Request"}`, body)
+	})
+}
@@ -0,0 +1,50 @@
+package router
+
+// BenchmarkRoute is one entry of BenchmarkTable: a route to register and a
+// request path that reaches it.
+type BenchmarkRoute struct {
+	Method  string
+	Pattern string
+	Path    string
+}
+
+// BenchmarkTable returns a standard route set shaped like a small REST API,
+// mixing static segments, parameters and several methods per path. It gives
+// benchmarks and conformance tests a shared, representative workload; every
+// Path resolves to its own entry once all routes are registered.
+func BenchmarkTable() []BenchmarkRoute {
+	return []BenchmarkRoute{
+		{Method: "GET", Pattern: "/", Path: "/"},
+		{Method: "GET", Pattern: "/health", Path: "/health"},
+		{Method: "GET", Pattern: "/metrics", Path: "/metrics"},
+		{Method: "GET", Pattern: "/static/css/site.css", Path: "/static/css/site.css"},
+		{Method: "GET", Pattern: "/static/js/app.js", Path: "/static/js/app.js"},
+
+		{Method: "GET", Pattern: "/users", Path: "/users"},
+		{Method: "POST", Pattern: "/users", Path: "/users"},
+		{Method: "GET", Pattern: "/users/me", Path: "/users/me"},
+		{Method: "GET", Pattern: "/users/:id", Path: "/users/42"},
+		{Method: "PUT", Pattern: "/users/:id", Path: "/users/42"},
+		{Method: "PATCH", Pattern: "/users/:id", Path: "/users/42"},
+		{Method: "DELETE", Pattern: "/users/:id", Path: "/users/42"},
+		{Method: "GET", Pattern: "/users/:id/posts", Path: "/users/42/posts"},
+		{Method: "POST", Pattern: "/users/:id/posts", Path: "/users/42/posts"},
+		{Method: "GET", Pattern: "/users/:id/posts/:post", Path: "/users/42/posts/7"},
+		{Method: "DELETE", Pattern: "/users/:id/posts/:post", Path: "/users/42/posts/7"},
+		{Method: "GET", Pattern: "/users/:id/followers", Path: "/users/42/followers"},
+
+		{Method: "GET", Pattern: "/orgs/:org/repos", Path: "/orgs/acme/repos"},
+		{Method: "POST", Pattern: "/orgs/:org/repos", Path: "/orgs/acme/repos"},
+		{Method: "GET", Pattern: "/orgs/:org/repos/:repo", Path: "/orgs/acme/repos/widget"},
+		{Method: "GET", Pattern: "/orgs/:org/repos/:repo/issues", Path: "/orgs/acme/repos/widget/issues"},
+		{Method: "GET", Pattern: "/orgs/:org/repos/:repo/issues/:number", Path: "/orgs/acme/repos/widget/issues/12"},
+		{Method: "PATCH", Pattern: "/orgs/:org/repos/:repo/issues/:number", Path: "/orgs/acme/repos/widget/issues/12"},
+		{Method: "GET", Pattern: "/orgs/:org/members", Path: "/orgs/acme/members"},
+
+		{Method: "GET", Pattern: "/search", Path: "/search"},
+		{Method: "GET", Pattern: "/api/v1/items", Path: "/api/v1/items"},
+		{Method: "GET", Pattern: "/api/v1/items/:id", Path: "/api/v1/items/99"},
+		{Method: "GET", Pattern: "/api/v2/items", Path: "/api/v2/items"},
+		{Method: "GET", Pattern: "/api/v2/items/:id", Path: "/api/v2/items/99"},
+	}
+}
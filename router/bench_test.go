@@ -0,0 +1,67 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// benchmarkRouter registers every route of BenchmarkTable.
+func benchmarkRouter() *Router {
+	r := New()
+	for _, br := range BenchmarkTable() {
+		r.AddRoute(br.Method, br.Pattern, textHandler(br.Pattern))
+	}
+	return r
+}
+
+func TestBenchmarkTable(t *testing.T) {
+	table := BenchmarkTable()
+	seen := make(map[string]bool)
+	for _, br := range table {
+		key := br.Method + " " + br.Pattern
+		assert.False(t, seen[key], "duplicate route %s", key)
+		seen[key] = true
+	}
+
+	r := benchmarkRouter()
+	for _, br := range table {
+		rt, _ := r.FindRoute(br.Method, br.Path)
+		require.NotNil(t, rt, "%s %s", br.Method, br.Path)
+		assert.Equal(t, br.Pattern, rt.Pattern, "%s %s", br.Method, br.Path)
+	}
+}
+
+func BenchmarkFindHandler(b *testing.B) {
+	r := benchmarkRouter()
+	table := BenchmarkTable()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		br := table[i%len(table)]
+		if handler, _ := r.FindHandler(br.Method, br.Path); handler == nil {
+			b.Fatalf("no handler for %s %s", br.Method, br.Path)
+		}
+	}
+}
+
+func BenchmarkFindHandlerMiss(b *testing.B) {
+	r := benchmarkRouter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.FindHandler("GET", "/orgs/acme/repos/widget/pulls")
+	}
+}
+
+func BenchmarkFindHandlerThreeParams(b *testing.B) {
+	r := benchmarkRouter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if handler, _ := r.FindHandler("GET", "/orgs/acme/repos/widget/issues/12"); handler == nil {
+			b.Fatal("no handler")
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package router
 
 import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mohdrashid9678/rhttp/request"
 	"github.com/mohdrashid9678/rhttp/response"
@@ -10,65 +15,261 @@ import (
 
 type Handler func(*request.Request) (*response.Response, error)
 
-// node represents a single node in the radix tree.
+// MethodAny registers a handler that serves every method for which the path
+// has no handler of its own.
+const MethodAny = "ANY"
+
+// node represents a single node in the radix tree. A node holds the routes of
+// every method registered for its path.
 type node struct {
 	path     string
 	part     string
 	children []*node
-	handlers map[string]Handler // Uses the local Handler type
+	handlers map[string]*Route
 	isParam  bool
+	// name is the parameter name of a param node, and isInt is set for
+	// params constrained with ":name:int".
+	name  string
+	isInt bool
 }
 
 // Thread safe router type
 type Router struct {
-	trees map[string]*node
-	mu    sync.RWMutex
+	// CaseInsensitive makes static path segments match regardless of case,
+	// so /Users/42 finds /users/:id. Param values keep the case they were
+	// sent in. Set it before adding routes.
+	CaseInsensitive bool
+
+	root   *node
+	routes []*Route
+	named  map[string]*Route
+	mu     sync.RWMutex
+}
+
+// Route is a registered route. It is returned by AddRoute so the route can be
+// configured further.
+type Route struct {
+	router  *Router
+	handler Handler
+	Method  string
+	Pattern string
+	// Timeout overrides the server's handler timeout for this route. Zero
+	// means the server default applies.
+	Timeout time.Duration
+	// params is the number of parameters in Pattern, used to size the param
+	// map of a match, and intParams the number of those constrained to
+	// integers.
+	params    int
+	intParams int
 }
 
 // New creates a new Router.
 func New() *Router {
-	return &Router{trees: make(map[string]*node)}
+	return &Router{
+		root:  &node{path: "/", part: "/"},
+		named: make(map[string]*Route),
+	}
 }
 
-// AddRoute now uses the local Handler type.
-func (r *Router) AddRoute(method, path string, handler Handler) {
+// AddRoute now uses the local Handler type. A parameter segment may be
+// constrained to integers by writing it as ":name:int"; such a segment only
+// matches values strconv.Atoi accepts, and the parsed value is returned by
+// FindRouteParams. AddRoute panics on an unknown parameter type.
+func (r *Router) AddRoute(method, path string, handler Handler) *Route {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.trees[method] == nil {
-		r.trees[method] = &node{path: "/", part: "/"}
+	rt := &Route{router: r, handler: handler, Method: method, Pattern: path}
+	rt.params, rt.intParams = countParams(path)
+	r.root.insert(path, rt, method, r.CaseInsensitive)
+	r.routes = append(r.routes, rt)
+	return rt
+}
+
+// Any registers handler for every method on path. Handlers registered for a
+// specific method on the same path take precedence, so Any acts as a
+// per-path fallback for the methods the path does not handle itself; it has
+// no effect on other paths.
+func (r *Router) Any(path string, handler Handler) *Route {
+	return r.AddRoute(MethodAny, path, handler)
+}
+
+// Mount registers every route of sub under prefix, so a sub-router's "/users"
+// becomes "/admin/users" when mounted at "/admin". It fails without
+// registering anything if a mounted route would replace an existing one.
+func (r *Router) Mount(prefix string, sub *Router) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && prefix[0] != '/' {
+		return fmt.Errorf("mount prefix '%s' must start with '/'", prefix)
+	}
+
+	sub.mu.RLock()
+	mounted := make([]Route, 0, len(sub.routes))
+	for _, rt := range sub.routes {
+		pattern := prefix + rt.Pattern
+		if rt.Pattern == "/" && prefix != "" {
+			pattern = prefix
+		}
+		mounted = append(mounted, Route{handler: rt.handler, Method: rt.Method, Pattern: pattern, Timeout: rt.Timeout})
+	}
+	sub.mu.RUnlock()
+
+	r.mu.RLock()
+	for _, m := range mounted {
+		for _, rt := range r.routes {
+			if rt.Method == m.Method && rt.Pattern == m.Pattern {
+				r.mu.RUnlock()
+				return fmt.Errorf("mounting at '%s' conflicts with existing route %s %s", prefix, m.Method, m.Pattern)
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, m := range mounted {
+		r.AddRoute(m.Method, m.Pattern, m.handler).WithTimeout(m.Timeout)
+	}
+	return nil
+}
+
+// Name registers the route under name so URL can build paths to it.
+func (rt *Route) Name(name string) *Route {
+	rt.router.mu.Lock()
+	defer rt.router.mu.Unlock()
+
+	rt.router.named[name] = rt
+	return rt
+}
+
+// WithTimeout sets how long the route's handler may run, overriding the
+// server's default in either direction.
+func (rt *Route) WithTimeout(d time.Duration) *Route {
+	rt.router.mu.Lock()
+	defer rt.router.mu.Unlock()
+
+	rt.Timeout = d
+	return rt
+}
+
+// Handler returns the handler registered for the route.
+func (rt *Route) Handler() Handler {
+	return rt.handler
+}
+
+// URL builds the path of the route registered under name, substituting params
+// for its parameter segments.
+func (r *Router) URL(name string, params map[string]string) (string, error) {
+	r.mu.RLock()
+	rt, ok := r.named[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no route named '%s'", name)
 	}
-	r.trees[method].insert(path, handler, method)
+
+	parts := strings.Split(rt.Pattern, "/")
+	for i, part := range parts {
+		if !strings.HasPrefix(part, ":") {
+			continue
+		}
+		key, _ := parseParam(part)
+		value, ok := params[key]
+		if !ok || value == "" {
+			return "", fmt.Errorf("missing parameter '%s' for route '%s'", key, name)
+		}
+		parts[i] = url.PathEscape(value)
+	}
+	return strings.Join(parts, "/"), nil
 }
 
-// FindHandler now returns the local Handler type.
+// FindHandler returns the handler registered for method at path, falling
+// back to a route added with Any, along with the path parameters. It returns
+// nil when the path matches nothing or only routes for other methods;
+// AllowedMethods tells those two cases apart.
 func (r *Router) FindHandler(method, path string) (Handler, map[string]string) {
+	rt, params := r.FindRoute(method, path)
+	if rt == nil {
+		return nil, nil
+	}
+	return rt.handler, params
+}
+
+// FindRoute is like FindHandler but returns the matched route, so callers can
+// see how it was configured.
+func (r *Router) FindRoute(method, path string) (*Route, map[string]string) {
+	rt, params, _ := r.FindRouteParams(method, path)
+	return rt, params
+}
+
+// FindRouteParams is like FindRoute but also returns the values of params
+// constrained with ":name:int", parsed while matching. The int map is nil
+// when the route has no such params. Lookups take the escaped path, such as
+// a request's RawPath, and decode each segment.
+func (r *Router) FindRouteParams(method, path string) (*Route, map[string]string, map[string]int) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if root := r.trees[method]; root != nil {
-		return root.search(path)
+	if rt, params, ints := r.root.search(path, method, r.CaseInsensitive); rt != nil {
+		return rt, params, ints
 	}
-	return nil, nil
+	return r.root.search(path, MethodAny, r.CaseInsensitive)
 }
 
-// insert adds a new route to the node's subtree.
-func (n *node) insert(path string, handler Handler, method string) {
+// Methods returns the sorted set of methods that have at least one route.
+func (r *Router) Methods() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.methods()
+}
+
+// methods is Methods without locking.
+func (r *Router) methods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, rt := range r.routes {
+		if rt.Method != MethodAny && !seen[rt.Method] {
+			seen[rt.Method] = true
+			methods = append(methods, rt.Method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// AllowedMethods returns the sorted methods that have a handler for path.
+func (r *Router) AllowedMethods(path string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var methods []string
+	for _, method := range r.methods() {
+		if rt, _, _ := r.root.search(path, method, r.CaseInsensitive); rt != nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// insert adds a new route to the node's subtree. With fold, static segments
+// are stored in lower case.
+func (n *node) insert(path string, rt *Route, method string, fold bool) {
 	parts := strings.Split(path, "/")[1:]
 	for i, part := range parts {
 		if part == "" && i == len(parts)-1 {
 			break
 		}
+		if fold && !strings.HasPrefix(part, ":") {
+			part = strings.ToLower(part)
+		}
 		child := n.findOrCreateChild(part)
 		n = child
 	}
 	if n.handlers == nil {
-		n.handlers = make(map[string]Handler)
+		n.handlers = make(map[string]*Route)
 	}
-	n.handlers[method] = handler
+	n.handlers[method] = rt
 }
 
-// findOrCreateChild finds a child node for a part or creates it.
+// findOrCreateChild finds a child node for a part or creates it. Int params
+// are kept ahead of plain ones so the more specific match is tried first.
 func (n *node) findOrCreateChild(part string) *node {
 	for _, child := range n.children {
 		if child.part == part {
@@ -79,43 +280,128 @@ func (n *node) findOrCreateChild(part string) *node {
 		part:    part,
 		isParam: len(part) > 0 && part[0] == ':',
 	}
+	if newChild.isParam {
+		var kind string
+		newChild.name, kind = parseParam(part)
+		switch kind {
+		case "":
+		case "int":
+			newChild.isInt = true
+			n.children = append([]*node{newChild}, n.children...)
+			return newChild
+		default:
+			panic(fmt.Sprintf("router: unknown type '%s' for parameter '%s'", kind, newChild.name))
+		}
+	}
 	n.children = append(n.children, newChild)
 	return newChild
 }
 
-// search finds a handler in the node's subtree.
-func (n *node) search(path string) (Handler, map[string]string) {
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	params := make(map[string]string)
-	currentNode := n
+// search finds the route registered for method at path in the node's
+// subtree. Static segments are tried before parameters, and a branch that
+// has no route for method is backed out of, so GET /users/new can reach a
+// static route even when a parameter route for another method shares the
+// prefix. path is the escaped request path: it is split on "/" before each
+// segment is unescaped, so an encoded slash stays inside its segment and
+// param values come out decoded. The param map is sized to the matched
+// route's parameter count, which also bounds it whatever the path looks
+// like. With fold, static segments are compared case-insensitively.
+func (n *node) search(path, method string, fold bool) (*Route, map[string]string, map[string]int) {
+	// Typical paths fit the stack buffers, so a match allocates only its map.
+	var partBuf [16]string
+	parts := partBuf[:0]
+	for rest := path; rest != ""; {
+		var part string
+		part, rest, _ = strings.Cut(rest, "/")
+		if strings.IndexByte(part, '%') >= 0 {
+			if unescaped, err := url.PathUnescape(part); err == nil {
+				part = unescaped
+			}
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	var buf [maxStackParams]param
+	rt, found := n.match(parts, method, fold, buf[:0])
+	if rt == nil {
+		return nil, nil, nil
+	}
+	params := make(map[string]string, rt.params)
+	var ints map[string]int
+	if rt.intParams > 0 {
+		ints = make(map[string]int, rt.intParams)
+	}
+	for _, p := range found {
+		params[p.key] = p.value
+		if p.isInt {
+			ints[p.key] = p.n
+		}
+	}
+	return rt, params, ints
+}
+
+// maxStackParams is how many params a match collects before its buffer has
+// to grow onto the heap.
+const maxStackParams = 8
 
-	for _, part := range parts {
-		if part == "" {
+// param is a path parameter collected while matching, with the parsed value
+// of an int param.
+type param struct {
+	key, value string
+	n          int
+	isInt      bool
+}
+
+// match resolves parts below n, appending to found the params of a
+// successful match on the way back up.
+func (n *node) match(parts []string, method string, fold bool, found []param) (*Route, []param) {
+	if len(parts) == 0 {
+		return n.handlers[method], found
+	}
+	part, rest := parts[0], parts[1:]
+	for _, child := range n.children {
+		if !child.isParam && (child.part == part || fold && strings.EqualFold(child.part, part)) {
+			if rt, found := child.match(rest, method, fold, found); rt != nil {
+				return rt, found
+			}
+		}
+	}
+	for _, child := range n.children {
+		if !child.isParam {
 			continue
 		}
-		var found bool
-		for _, child := range currentNode.children {
-			if child.isParam {
-				params[child.part[1:]] = part
-				currentNode = child
-				found = true
-				break
-			}
-			if child.part == part {
-				currentNode = child
-				found = true
-				break
+		p := param{key: child.name, value: part, isInt: child.isInt}
+		if child.isInt {
+			var err error
+			if p.n, err = strconv.Atoi(part); err != nil {
+				continue
 			}
 		}
-		if !found {
-			return nil, nil
+		if rt, found := child.match(rest, method, fold, found); rt != nil {
+			return rt, append(found, p)
 		}
 	}
+	return nil, found
+}
 
-	if len(currentNode.handlers) > 0 {
-		for _, handler := range currentNode.handlers {
-			return handler, params
+// countParams returns the number of parameter segments in pattern, and how
+// many of them are int params.
+func countParams(pattern string) (params, ints int) {
+	for _, part := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(part, ":") {
+			params++
+			if _, kind := parseParam(part); kind == "int" {
+				ints++
+			}
 		}
 	}
-	return nil, nil
+	return params, ints
+}
+
+// parseParam splits a parameter segment such as ":id:int" into its name and
+// type; the type is empty for unconstrained params.
+func parseParam(part string) (name, kind string) {
+	name, kind, _ = strings.Cut(part[1:], ":")
+	return name, kind
 }
@@ -0,0 +1,337 @@
+package router
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textHandler returns a handler that responds with the given body.
+func textHandler(body string) Handler {
+	return func(req *request.Request) (*response.Response, error) {
+		return response.Text(200, body)
+	}
+}
+
+// bodyOf invokes handler and returns its response body.
+func bodyOf(t *testing.T, handler Handler) string {
+	t.Helper()
+	resp, err := handler(&request.Request{})
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestAllowedMethods(t *testing.T) {
+	r := New()
+	r.AddRoute("POST", "/users/:id", textHandler("post"))
+	r.AddRoute("GET", "/users/:id", textHandler("get"))
+	r.AddRoute("DELETE", "/users/:id", textHandler("delete"))
+	r.AddRoute("GET", "/health", textHandler("health"))
+
+	assert.Equal(t, []string{"DELETE", "GET", "POST"}, r.AllowedMethods("/users/42"))
+	assert.Equal(t, []string{"GET"}, r.AllowedMethods("/health"))
+	assert.Empty(t, r.AllowedMethods("/unknown"))
+}
+
+func TestAnyMethod(t *testing.T) {
+	r := New()
+	r.Any("/x", textHandler("any"))
+	r.AddRoute("GET", "/x", textHandler("get"))
+
+	testCases := []struct {
+		method string
+		body   string
+	}{
+		{method: "GET", body: "get"},
+		{method: "DELETE", body: "any"},
+		{method: "PATCH", body: "any"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			handler, _ := r.FindHandler(tc.method, "/x")
+			require.NotNil(t, handler)
+			assert.Equal(t, tc.body, bodyOf(t, handler))
+		})
+	}
+
+	handler, _ := r.FindHandler("DELETE", "/y")
+	assert.Nil(t, handler, "ANY must not match other paths")
+	assert.Equal(t, []string{"GET"}, r.AllowedMethods("/x"))
+}
+
+func TestURL(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/users/:id", textHandler("show")).Name("user.show")
+	r.AddRoute("GET", "/users/:id/posts/:post", textHandler("post")).Name("user.post")
+	r.AddRoute("GET", "/about", textHandler("about")).Name("about")
+
+	testCases := []struct {
+		name      string
+		route     string
+		params    map[string]string
+		expected  string
+		expectErr bool
+	}{
+		{name: "Single param", route: "user.show", params: map[string]string{"id": "42"}, expected: "/users/42"},
+		{name: "Two params", route: "user.post", params: map[string]string{"id": "1", "post": "9"}, expected: "/users/1/posts/9"},
+		{name: "Escapes values", route: "user.show", params: map[string]string{"id": "a b/c"}, expected: "/users/a%20b%2Fc"},
+		{name: "Static route", route: "about", expected: "/about"},
+		{name: "Missing param", route: "user.post", params: map[string]string{"id": "1"}, expectErr: true},
+		{name: "Unknown route", route: "nope", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := r.URL(tc.route, tc.params)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestURLRoundTrip(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/users/:name/files/:file", textHandler("file")).Name("file")
+
+	params := map[string]string{"name": "John Doe", "file": "a/b%c.txt"}
+	path, err := r.URL("file", params)
+	require.NoError(t, err)
+	assert.Equal(t, "/users/John%20Doe/files/a%2Fb%25c.txt", path)
+
+	handler, found := r.FindHandler("GET", path)
+	require.NotNil(t, handler)
+	assert.Equal(t, params, found)
+}
+
+func TestMount(t *testing.T) {
+	admin := New()
+	admin.AddRoute("GET", "/", textHandler("dashboard"))
+	admin.AddRoute("GET", "/users/:id", textHandler("user"))
+	admin.AddRoute("DELETE", "/users/:id", textHandler("delete user"))
+
+	r := New()
+	r.AddRoute("GET", "/users/:id", textHandler("public user"))
+	require.NoError(t, r.Mount("/admin", admin))
+
+	testCases := []struct {
+		method string
+		path   string
+		body   string
+		id     string
+	}{
+		{method: "GET", path: "/admin", body: "dashboard"},
+		{method: "GET", path: "/admin/users/7", body: "user", id: "7"},
+		{method: "DELETE", path: "/admin/users/7", body: "delete user", id: "7"},
+		{method: "GET", path: "/users/7", body: "public user", id: "7"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			handler, params := r.FindHandler(tc.method, tc.path)
+			require.NotNil(t, handler)
+			assert.Equal(t, tc.body, bodyOf(t, handler))
+			if tc.id != "" {
+				assert.Equal(t, tc.id, params["id"])
+			}
+		})
+	}
+
+	t.Run("Conflict", func(t *testing.T) {
+		other := New()
+		other.AddRoute("GET", "/users/:id", textHandler("other"))
+		other.AddRoute("GET", "/extra", textHandler("extra"))
+
+		assert.Error(t, r.Mount("/admin", other))
+		handler, _ := r.FindHandler("GET", "/admin/extra")
+		assert.Nil(t, handler, "a failed mount must not register any route")
+	})
+}
+
+func TestFindRouteKeepsTimeout(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/report", textHandler("report")).WithTimeout(time.Minute)
+
+	rt, _ := r.FindRoute("GET", "/report")
+	require.NotNil(t, rt)
+	assert.Equal(t, time.Minute, rt.Timeout)
+
+	admin := New()
+	require.NoError(t, admin.Mount("/admin", r))
+	rt, _ = admin.FindRoute("GET", "/admin/report")
+	require.NotNil(t, rt)
+	assert.Equal(t, time.Minute, rt.Timeout, "mounting keeps the route's timeout")
+}
+
+func TestAnyIsPathScopedFallback(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/files/:name", textHandler("get file"))
+	r.Any("/files/:name", textHandler("fallback"))
+	r.AddRoute("PUT", "/other", textHandler("put other"))
+
+	testCases := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: "GET", path: "/files/a.txt", body: "get file"},
+		{method: "PUT", path: "/files/a.txt", body: "fallback"},
+		{method: "DELETE", path: "/files/a.txt", body: "fallback"},
+		{method: "PUT", path: "/other", body: "put other"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			handler, params := r.FindHandler(tc.method, tc.path)
+			require.NotNil(t, handler)
+			assert.Equal(t, tc.body, bodyOf(t, handler))
+			if tc.path == "/files/a.txt" {
+				assert.Equal(t, "a.txt", params["name"])
+			}
+		})
+	}
+
+	handler, _ := r.FindHandler("DELETE", "/other")
+	assert.Nil(t, handler, "the fallback is scoped to its own path")
+}
+
+func TestSearchMatchesMethod(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/items/:id", textHandler("get"))
+	r.AddRoute("POST", "/items/:id", textHandler("post"))
+	r.AddRoute("PUT", "/items/new", textHandler("put new"))
+
+	// Repeat the lookups so a result depending on map iteration order
+	// would show up.
+	for i := 0; i < 50; i++ {
+		for method, want := range map[string]string{"GET": "get", "POST": "post"} {
+			handler, params := r.FindHandler(method, "/items/7")
+			require.NotNil(t, handler, method)
+			assert.Equal(t, want, bodyOf(t, handler))
+			assert.Equal(t, "7", params["id"])
+		}
+	}
+
+	// The static child has no GET route, so the lookup backs out of it and
+	// takes the parameter branch instead.
+	handler, params := r.FindHandler("GET", "/items/new")
+	require.NotNil(t, handler)
+	assert.Equal(t, "get", bodyOf(t, handler))
+	assert.Equal(t, "new", params["id"])
+
+	handler, _ = r.FindHandler("DELETE", "/items/7")
+	assert.Nil(t, handler)
+}
+
+func TestFindHandlerMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/reports/:id", textHandler("get"))
+	r.AddRoute("DELETE", "/reports/:id", textHandler("delete"))
+
+	handler, _ := r.FindHandler("DELETE", "/reports/3")
+	require.NotNil(t, handler)
+	assert.Equal(t, "delete", bodyOf(t, handler))
+
+	// The path exists but not for PUT: a caller can answer 405 with an
+	// Allow header rather than 404.
+	handler, params := r.FindHandler("PUT", "/reports/3")
+	assert.Nil(t, handler)
+	assert.Nil(t, params)
+	assert.Equal(t, []string{"DELETE", "GET"}, r.AllowedMethods("/reports/3"))
+
+	handler, _ = r.FindHandler("PUT", "/missing")
+	assert.Nil(t, handler)
+	assert.Empty(t, r.AllowedMethods("/missing"))
+}
+
+func TestSearchParams(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/orgs/:org/repos/:repo/issues/:number", textHandler("issue"))
+	r.AddRoute("GET", "/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/:last", textHandler("deep"))
+
+	handler, params := r.FindHandler("GET", "/orgs/acme/repos/widget/issues/12")
+	require.NotNil(t, handler)
+	assert.Equal(t, map[string]string{"org": "acme", "repo": "widget", "number": "12"}, params)
+
+	// The static branch is tried first and backed out of; nothing it
+	// collected is kept.
+	r.AddRoute("GET", "/files/static/:name/raw", textHandler("raw"))
+	r.AddRoute("GET", "/files/:dir/:name", textHandler("file"))
+	handler, params = r.FindHandler("GET", "/files/static/logo.png")
+	require.NotNil(t, handler)
+	assert.Equal(t, "file", bodyOf(t, handler))
+	assert.Equal(t, map[string]string{"dir": "static", "name": "logo.png"}, params)
+
+	// Paths longer than the stack buffer still match.
+	handler, params = r.FindHandler("GET", "/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q/r")
+	require.NotNil(t, handler)
+	assert.Equal(t, map[string]string{"last": "r"}, params)
+
+	handler, params = r.FindHandler("GET", "/orgs/acme/repos/widget/issues/12/extra")
+	assert.Nil(t, handler)
+	assert.Nil(t, params)
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/users/:id", textHandler("user"))
+	handler, _ := r.FindHandler("GET", "/Users/42")
+	assert.Nil(t, handler)
+
+	r = New()
+	r.CaseInsensitive = true
+	r.AddRoute("GET", "/users/:id", textHandler("user"))
+	r.AddRoute("GET", "/Reports/Annual", textHandler("annual"))
+
+	handler, params := r.FindHandler("GET", "/Users/42")
+	require.NotNil(t, handler)
+	assert.Equal(t, "user", bodyOf(t, handler))
+	assert.Equal(t, map[string]string{"id": "42"}, params)
+
+	// Param values, including percent-encoded ones, keep their case.
+	_, params = r.FindHandler("GET", "/USERS/Ada%2FLovelace")
+	assert.Equal(t, map[string]string{"id": "Ada/Lovelace"}, params)
+
+	handler, _ = r.FindHandler("GET", "/reports/annual")
+	require.NotNil(t, handler)
+	assert.Equal(t, "annual", bodyOf(t, handler))
+	assert.Equal(t, []string{"GET"}, r.AllowedMethods("/REPORTS/ANNUAL"))
+}
+
+func TestIntParams(t *testing.T) {
+	r := New()
+	r.AddRoute("GET", "/orders/:id:int", textHandler("order")).Name("order")
+	r.AddRoute("GET", "/users/:name", textHandler("by name"))
+	r.AddRoute("GET", "/users/:id:int", textHandler("by id"))
+
+	rt, params, ints := r.FindRouteParams("GET", "/orders/42")
+	require.NotNil(t, rt)
+	assert.Equal(t, map[string]string{"id": "42"}, params)
+	assert.Equal(t, map[string]int{"id": 42}, ints)
+
+	rt, _, _ = r.FindRouteParams("GET", "/orders/abc")
+	assert.Nil(t, rt)
+
+	// The int param is tried first even though it was added last.
+	handler, _ := r.FindHandler("GET", "/users/7")
+	require.NotNil(t, handler)
+	assert.Equal(t, "by id", bodyOf(t, handler))
+	rt, params, ints = r.FindRouteParams("GET", "/users/ada")
+	require.NotNil(t, rt)
+	assert.Equal(t, "by name", bodyOf(t, rt.Handler()))
+	assert.Equal(t, map[string]string{"name": "ada"}, params)
+	assert.Nil(t, ints)
+
+	url, err := r.URL("order", map[string]string{"id": "9"})
+	require.NoError(t, err)
+	assert.Equal(t, "/orders/9", url)
+
+	assert.Panics(t, func() { r.AddRoute("GET", "/files/:id:uuid", textHandler("file")) })
+}
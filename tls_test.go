@@ -0,0 +1,146 @@
+package rhttp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohdrashid9678/rhttp/middleware"
+	"github.com/mohdrashid9678/rhttp/request"
+	"github.com/mohdrashid9678/rhttp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCert creates a self-signed certificate for commonName, usable as a
+// server certificate for 127.0.0.1, as a client certificate, and as the CA
+// that verifies itself.
+func newTestCert(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// serveTLS serves s on a TLS listener configured by config and returns its
+// address.
+func serveTLS(t *testing.T, s *Server, config *tls.Config) string {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go s.Serve(listener)
+	return listener.Addr().String()
+}
+
+// tlsRoundTrip sends raw over a TLS connection to addr and returns the
+// response status line and body.
+func tlsRoundTrip(t *testing.T, addr string, config *tls.Config, raw string) (string, string) {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, config)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(raw))
+	require.NoError(t, err)
+	resp, body := readResponse(t, bufio.NewReader(conn))
+	return resp.Status, body
+}
+
+func TestRequestTLSState(t *testing.T) {
+	s := New(":0")
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		if req.TLS == nil {
+			return response.Text(200, "plaintext")
+		}
+		return response.Text(200, fmt.Sprintf("tls %x", req.TLS.Version))
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		cert := newTestCert(t, "server")
+		addr := serveTLS(t, s, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13})
+
+		status, body := tlsRoundTrip(t, addr, &tls.Config{InsecureSkipVerify: true},
+			"GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+		assert.Equal(t, "200 OK", status)
+		assert.Equal(t, fmt.Sprintf("tls %x", tls.VersionTLS13), body)
+	})
+
+	t.Run("Plaintext", func(t *testing.T) {
+		out := roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		assert.True(t, strings.HasSuffix(out, "\r\n\r\nplaintext"))
+	})
+}
+
+func TestRequireClientCert(t *testing.T) {
+	serverCert := newTestCert(t, "server")
+	trusted := newTestCert(t, "alice")
+	banned := newTestCert(t, "mallory")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(trusted.Leaf)
+	clientCAs.AddCert(banned.Leaf)
+
+	s := New(":0")
+	s.Use(middleware.RequireClientCert(func(cert *x509.Certificate) error {
+		if cert.Subject.CommonName == "mallory" {
+			return errors.New("revoked")
+		}
+		return nil
+	}))
+	s.AddRoute("GET", "/", func(req *request.Request) (*response.Response, error) {
+		subject, _ := req.Get(middleware.ClientCertSubjectKey)
+		return response.Text(200, "hello "+subject.(pkix.Name).CommonName)
+	})
+	addr := serveTLS(t, s, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    clientCAs,
+	})
+
+	testCases := []struct {
+		name   string
+		certs  []tls.Certificate
+		status string
+		body   string
+	}{
+		{name: "No client certificate", status: "401 Unauthorized"},
+		{name: "Accepted certificate", certs: []tls.Certificate{trusted}, status: "200 OK", body: "hello alice"},
+		{name: "Rejected by verify", certs: []tls.Certificate{banned}, status: "403 Forbidden"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &tls.Config{InsecureSkipVerify: true, Certificates: tc.certs}
+			status, body := tlsRoundTrip(t, addr, config, "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+			assert.Equal(t, tc.status, status)
+			if tc.body != "" {
+				assert.Equal(t, tc.body, body)
+			}
+		})
+	}
+}